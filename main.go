@@ -1,18 +1,13 @@
 package main
 
 import (
-	"go1f/pkg/db"
-	"go1f/pkg/server"
+	"go1f/pkg/cli"
 	"log"
+	"os"
 )
 
 func main() {
-	if err := db.Init(); err != nil {
-		log.Fatalf("error initializing database: %v", err)
+	if err := cli.Run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
 	}
-	defer db.DB.Close()
-
-	db.SetDB(db.DB)
-
-	server.StartServer()
 }
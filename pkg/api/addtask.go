@@ -3,12 +3,13 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"go1f/pkg/dates"
 	"go1f/pkg/db"
 	"net/http"
 	"time"
 )
 
-func addTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var task db.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -22,13 +23,19 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if task.Status != "" && !db.ValidStatus(task.Status) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "unknown task status"})
+		return
+	}
+
 	if err := checkDate(&task); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
 
-	id, err := db.AddTask(&task)
+	id, err := a.repo(r).AddTask(&task)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		writeJSON(w, map[string]string{"error": fmt.Sprintf("error adding task: %v", err)})
@@ -38,6 +45,8 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]string{"id": fmt.Sprintf("%d", id)})
 }
 
+// checkDate приводит дату задачи к формату YYYYMMDD (см. dates.Parse) и
+// переносит прошедшую дату на сегодня или на следующий повтор.
 func checkDate(task *db.Task) error {
 	now := time.Now().UTC()
 	nowDate, _ := time.Parse(dateFormat, now.Format(dateFormat))
@@ -46,10 +55,11 @@ func checkDate(task *db.Task) error {
 		task.Date = now.Format(dateFormat)
 	}
 
-	t, err := time.Parse(dateFormat, task.Date)
+	t, err := dates.Parse(task.Date, now)
 	if err != nil {
 		return fmt.Errorf("invalid date format")
 	}
+	task.Date = t.Format(dateFormat)
 
 	if task.Repeat != "" {
 		next, err := NextDate(nowDate, task.Date, task.Repeat)
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"go1f/pkg/dates"
+	"go1f/pkg/db"
+)
+
+// agendaDays — длина повестки, если to не задан.
+const agendaDays = 7
+
+// AgendaDay — задачи одного дня повестки.
+type AgendaDay struct {
+	Date  string     `json:"date"`
+	Tasks []*db.Task `json:"tasks"`
+}
+
+type AgendaResp struct {
+	Days []*AgendaDay `json:"days"`
+}
+
+// AgendaHandler отдаёт задачи по дням диапазона from..to для календаря и
+// недельного вида. Повторяющаяся задача попадает в каждый свой день, а
+// вычисленные повторы отмечены virtual. В ответе есть все дни диапазона,
+// в том числе пустые. По умолчанию диапазон — неделя с сегодняшнего дня.
+func (a *API) AgendaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rng, err := parseAgendaRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now().UTC())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	search := r.URL.Query().Get("search")
+	if _, err := db.ParseQuery(search); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	statuses, err := parseStatuses(r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+
+	filter := db.TaskFilter{
+		Search:    search,
+		Statuses:  statuses,
+		From:      rng.from,
+		To:        rng.to,
+		Repeating: true,
+	}
+	tasks, err := a.repo(r).Tasks(math.MaxInt, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, AgendaResp{Days: agendaDaysOf(expandTasks(tasks, rng), rng)})
+}
+
+// parseAgendaRange разбирает границы повестки в любом формате, который
+// понимает dates.Parse. Без from повестка начинается сегодня, без to длится agendaDays дней.
+func parseAgendaRange(from, to string, now time.Time) (*dateRange, error) {
+	rng := &dateRange{from: now.Format(dateFormat), expand: true}
+	var err error
+	if from != "" {
+		if rng.from, err = dates.Normalize(from, now); err != nil {
+			return nil, fmt.Errorf("invalid from date %q", from)
+		}
+	}
+	if to == "" {
+		start, _ := time.Parse(dateFormat, rng.from)
+		rng.to = start.AddDate(0, 0, agendaDays-1).Format(dateFormat)
+		return rng, nil
+	}
+	if rng.to, err = dates.Normalize(to, now); err != nil {
+		return nil, fmt.Errorf("invalid to date %q", to)
+	}
+	if err := checkSpan(rng.from, rng.to); err != nil {
+		return nil, err
+	}
+	return rng, nil
+}
+
+// agendaDaysOf раскладывает повторы, упорядоченные expandTasks, по дням
+// диапазона.
+func agendaDaysOf(tasks []*db.Task, rng *dateRange) []*AgendaDay {
+	start, _ := time.Parse(dateFormat, rng.from)
+	var days []*AgendaDay
+	for day := start; day.Format(dateFormat) <= rng.to; day = day.AddDate(0, 0, 1) {
+		days = append(days, &AgendaDay{Date: day.Format(dateFormat), Tasks: make([]*db.Task, 0)})
+	}
+	for _, task := range tasks {
+		day, _ := time.Parse(dateFormat, task.Date)
+		i := int(day.Sub(start).Hours() / 24)
+		days[i].Tasks = append(days[i].Tasks, task)
+	}
+	return days
+}
@@ -1,14 +1,54 @@
 package api
 
-import "net/http"
+import (
+	"go1f/pkg/db"
+	"net/http"
+)
 
-func Init() {
-	http.HandleFunc("/api/nextdate", nextDayHandler)
+// API содержит обработчики запросов и хранилище задач, с которым они работают.
+type API struct {
+	store db.Store
+	stats *statsCache
 }
 
-// Экспортируем функции, связанные с аутентификацией
-var (
-	SignInHandler = signInHandler
-	Auth          = auth
-	TasksHandler  = tasksHandler
-)
+func New(store db.Store) *API {
+	return &API{store: store, stats: &statsCache{}}
+}
+
+// Register регистрирует обработчики API в mux. Всё, кроме входа,
+// вычисления следующей даты и ленты календаря, доступно только после
+// аутентификации; лента защищена собственным токеном.
+func (a *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/nextdate", nextDayHandler)
+	mux.HandleFunc("/api/signin", signInHandler)
+	mux.HandleFunc("/api/task", auth(a.TaskHandler))
+	mux.HandleFunc("/api/task/done", auth(a.DoneTaskHandler))
+	mux.HandleFunc("/api/task/status", auth(a.StatusTaskHandler))
+	mux.HandleFunc("/api/task/snooze", auth(a.SnoozeTaskHandler))
+	mux.HandleFunc("/api/task/history", auth(a.HistoryHandler))
+	mux.HandleFunc("/api/task/revert", auth(a.RevertTaskHandler))
+	mux.HandleFunc("/api/tasks", auth(a.tasksHandler))
+	mux.HandleFunc("/api/tasks/batch", auth(a.BatchHandler))
+	mux.HandleFunc("/api/agenda", auth(a.AgendaHandler))
+	mux.HandleFunc("/api/stats", auth(a.StatsHandler))
+	mux.HandleFunc("/api/template", auth(a.TemplateHandler))
+	mux.HandleFunc("/api/template/apply", auth(a.ApplyTemplateHandler))
+	mux.HandleFunc("/api/templates", auth(a.TemplatesHandler))
+	mux.HandleFunc("/api/search", auth(a.SavedSearchHandler))
+	mux.HandleFunc("/api/searches", auth(a.SavedSearchesHandler))
+	mux.HandleFunc("/api/backup", auth(a.BackupHandler))
+	mux.HandleFunc("/api/export", auth(a.ExportHandler))
+	mux.HandleFunc("/api/import", auth(a.ImportHandler))
+	for format := range importFormats {
+		mux.HandleFunc("/api/import/"+format, auth(a.ImportFileHandler(format)))
+	}
+	mux.HandleFunc("/api/feed", auth(a.FeedHandler))
+	mux.HandleFunc("/api/feeds", auth(a.FeedsHandler))
+	mux.HandleFunc("/api/calendar.ics", a.CalendarHandler)
+}
+
+// repo возвращает хранилище задач; изменения через него записываются
+// в историю от имени автора запроса.
+func (a *API) repo(r *http.Request) db.Store {
+	return a.store.WithActor(actorOf(r))
+}
@@ -0,0 +1,1015 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go1f/pkg/db"
+	"go1f/pkg/db/memstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAPI(t *testing.T) (*http.ServeMux, db.Store) {
+	t.Setenv("TODO_PASSWORD", "")
+	// Тесты обработчиков пишут задачи без версий; требование версии
+	// проверяет TestVersionConflict.
+	t.Setenv("TODO_UNVERSIONED_WRITES", "1")
+
+	store := memstore.New()
+	mux := http.NewServeMux()
+	New(store).Register(mux)
+	return mux, store
+}
+
+func do(t *testing.T, mux *http.ServeMux, method, target string, body any,
+	headers map[string]string) (*httptest.ResponseRecorder, map[string]any) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m), rec.Body.String())
+	return rec, m
+}
+
+func today() string {
+	return time.Now().UTC().Format(dateFormat)
+}
+
+func TestTaskCRUD(t *testing.T) {
+	mux, _ := newTestAPI(t)
+
+	_, m := do(t, mux, http.MethodPost, "/api/task",
+		map[string]any{"date": today(), "title": "Купить хлеб"}, nil)
+	id, ok := m["id"].(string)
+	require.True(t, ok, m)
+
+	rec, m := do(t, mux, http.MethodGet, "/api/task?id="+id, nil, nil)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	assert.Equal(t, "Купить хлеб", m["title"])
+	assert.Equal(t, db.StatusTodo, m["status"])
+
+	rec, _ = do(t, mux, http.MethodPut, "/api/task",
+		map[string]any{"id": id, "date": today(), "title": "Купить хлеб и молоко"}, nil)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	_, m = do(t, mux, http.MethodGet, "/api/tasks?search=молоко", nil, nil)
+	assert.Len(t, m["tasks"], 1)
+	_, m = do(t, mux, http.MethodGet, "/api/tasks?search=repeat:none+-хлеб", nil, nil)
+	assert.Empty(t, m["tasks"])
+	rec, _ = do(t, mux, http.MethodGet, "/api/tasks?search=before:завтра", nil, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec, m = do(t, mux, http.MethodGet, "/api/tasks?search=before:когда-то", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, m["error"], "before: invalid date")
+
+	_, m = do(t, mux, http.MethodDelete, "/api/task?id="+id, nil, nil)
+	assert.Empty(t, m)
+
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil, nil)
+	assert.Equal(t, db.ErrNotFound.Error(), m["error"])
+}
+
+func TestTasksPages(t *testing.T) {
+	mux, store := newTestAPI(t)
+	_, err := store.AddTasks([]*db.Task{
+		{Date: "20240103", Title: "Задача 1"},
+		{Date: "20240101", Title: "Задача 2"},
+		{Date: "20240103", Title: "Задача 3"},
+		{Date: "20240102", Title: "Задача 4"},
+		{Date: "20240103", Title: "Задача 5"},
+	})
+	require.NoError(t, err)
+
+	pages := func(query string) (titles []string, count int) {
+		target := "/api/tasks?limit=2&" + query
+		for {
+			_, m := do(t, mux, http.MethodGet, target, nil, nil)
+			count++
+			for _, task := range m["tasks"].([]any) {
+				titles = append(titles, task.(map[string]any)["title"].(string))
+			}
+			cursor, ok := m["next_cursor"].(string)
+			if !ok {
+				return titles, count
+			}
+			target = "/api/tasks?limit=2&" + query + "&cursor=" + cursor
+		}
+	}
+
+	titles, count := pages("")
+	assert.Equal(t, []string{"Задача 2", "Задача 4", "Задача 1", "Задача 3", "Задача 5"}, titles)
+	assert.Equal(t, 3, count)
+	titles, _ = pages("search=задача")
+	assert.Len(t, titles, 5)
+	titles, count = pages("search=03.01.2024")
+	assert.Equal(t, []string{"Задача 1", "Задача 3", "Задача 5"}, titles)
+	assert.Equal(t, 2, count)
+
+	for _, query := range []string{"limit=0", "limit=много", "cursor=abc", "cursor=eyJvIjotMX0"} {
+		rec, m := do(t, mux, http.MethodGet, "/api/tasks?"+query, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.NotEmpty(t, m["error"], query)
+	}
+	_, m := do(t, mux, http.MethodGet, "/api/tasks?limit=100000", nil, nil)
+	assert.Len(t, m["tasks"], 5)
+}
+
+func TestTasksDateRange(t *testing.T) {
+	mux, store := newTestAPI(t)
+	now := time.Now().UTC()
+	day := func(offset int) string { return now.AddDate(0, 0, offset).Format(dateFormat) }
+	_, err := store.AddTasks([]*db.Task{
+		{Date: day(-2), Title: "Просрочена"},
+		{Date: day(0), Title: "Каждую неделю", Repeat: "d 7"},
+		{Date: day(3), Title: "Разовая"},
+		{Date: day(-1), Title: "Через день", Repeat: "d 2"},
+	})
+	require.NoError(t, err)
+
+	type occurrence struct {
+		Title   string
+		Date    string
+		Virtual bool
+	}
+	list := func(query string) (occs []occurrence) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp struct {
+			Tasks []occurrence `json:"tasks"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Tasks
+	}
+
+	assert.Equal(t, []occurrence{{"Просрочена", day(-2), false}, {"Через день", day(-1), false}}, list("due=overdue"))
+	assert.Equal(t, []occurrence{{"Каждую неделю", day(0), false}}, list("due=today"))
+
+	occs := list("days=14")
+	require.Len(t, occs, 10)
+	assert.Equal(t, occurrence{"Каждую неделю", day(0), false}, occs[0])
+	assert.Equal(t, occurrence{"Через день", day(1), true}, occs[1])
+	assert.Equal(t, occurrence{"Разовая", day(3), false}, occs[2])
+	assert.Equal(t, occurrence{"Через день", day(3), true}, occs[3])
+	assert.Contains(t, occs, occurrence{"Каждую неделю", day(7), true})
+
+	from := now.AddDate(0, 0, 3).Format("02.01.2006")
+	assert.Equal(t, list("days=14")[2:], list("from="+from+"&to="+day(13)))
+	assert.Len(t, list("from="+day(0)), 2, "без to повторы не разворачиваются")
+
+	var paged []occurrence
+	for target := "/api/tasks?days=14&limit=4"; ; {
+		_, m := do(t, mux, http.MethodGet, target, nil, nil)
+		for _, task := range m["tasks"].([]any) {
+			task := task.(map[string]any)
+			paged = append(paged, occurrence{task["title"].(string), task["date"].(string), task["virtual"] == true})
+		}
+		cursor, ok := m["next_cursor"].(string)
+		if !ok {
+			break
+		}
+		target = "/api/tasks?days=14&limit=4&cursor=" + cursor
+	}
+	assert.Equal(t, occs, paged)
+
+	for _, query := range []string{"due=someday", "days=0", "days=400", "due=today&days=3",
+		"from=" + day(5) + "&to=" + day(1), "from=" + day(0) + "&to=" + day(400), "from=когда-то"} {
+		rec, m := do(t, mux, http.MethodGet, "/api/tasks?"+query, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.NotEmpty(t, m["error"], query)
+	}
+}
+
+func TestAgenda(t *testing.T) {
+	mux, store := newTestAPI(t)
+	now := time.Now().UTC()
+	day := func(offset int) string { return now.AddDate(0, 0, offset).Format(dateFormat) }
+	_, err := store.AddTasks([]*db.Task{
+		{Date: day(-1), Title: "Через день", Repeat: "d 2"},
+		{Date: day(2), Title: "Разовая +дом"},
+		{Date: day(9), Title: "Позже"},
+	})
+	require.NoError(t, err)
+
+	type agenda struct {
+		Days []struct {
+			Date  string
+			Tasks []struct {
+				Title   string
+				Virtual bool
+			}
+		}
+	}
+	get := func(query string) (resp agenda) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/agenda?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	week := get("")
+	require.Len(t, week.Days, 7)
+	var titles [][]string
+	for i, d := range week.Days {
+		assert.Equal(t, day(i), d.Date)
+		day := []string{}
+		for _, task := range d.Tasks {
+			day = append(day, task.Title)
+			assert.Equal(t, task.Title == "Через день", task.Virtual, task.Title)
+		}
+		titles = append(titles, day)
+	}
+	assert.Equal(t, [][]string{{}, {"Через день"}, {"Разовая +дом"}, {"Через день"}, {}, {"Через день"}, {}}, titles)
+
+	days := get("from=" + day(2) + "&to=" + day(3) + "&search=tag:дом")
+	require.Len(t, days.Days, 2)
+	require.Len(t, days.Days[0].Tasks, 1)
+	assert.Equal(t, "Разовая +дом", days.Days[0].Tasks[0].Title)
+	assert.False(t, days.Days[0].Tasks[0].Virtual)
+	assert.Empty(t, days.Days[1].Tasks)
+
+	days = get("from=" + day(-1) + "&to=" + day(-1))
+	require.Len(t, days.Days, 1)
+	require.Len(t, days.Days[0].Tasks, 1)
+	assert.False(t, days.Days[0].Tasks[0].Virtual)
+
+	for _, query := range []string{"to=" + day(-1), "from=когда-то", "from=" + day(3) + "&to=" + day(1),
+		"from=" + day(0) + "&to=" + day(400), "search=%22open", "status=lost"} {
+		rec, m := do(t, mux, http.MethodGet, "/api/agenda?"+query, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.NotEmpty(t, m["error"], query)
+	}
+}
+
+func TestStats(t *testing.T) {
+	mux, store := newTestAPI(t)
+	now := time.Now().UTC()
+	day := func(offset int) string { return now.AddDate(0, 0, offset).Format(dateFormat) }
+	_, err := store.AddTasks([]*db.Task{
+		{Date: day(0), Title: "Зарядка", Repeat: "d 1"},
+		{Date: day(-1), Title: "Отчёт"},
+		{Date: day(-3), Title: "Забытая"},
+	})
+	require.NoError(t, err)
+	for _, id := range []string{"1", "1", "2"} {
+		rec, m := do(t, mux, http.MethodPost, "/api/task/done?id="+id, nil, nil)
+		require.Equal(t, http.StatusOK, rec.Code, m)
+	}
+
+	stats := func(query string) (resp StatsResp) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := stats("")
+	assert.Equal(t, day(-29), resp.From)
+	assert.Equal(t, day(0), resp.To)
+	require.Len(t, resp.Days, 30)
+	assert.Equal(t, DayStats{Date: day(0), Done: 3, Late: 1}, *resp.Days[29])
+	assert.Equal(t, 3, resp.Done)
+	assert.Equal(t, 1, resp.Overdue)
+	assert.Equal(t, 0.33, resp.AvgLateness)
+	assert.Equal(t, []*Streak{{TaskID: "1", Title: "Зарядка", Current: 2, Longest: 2}}, resp.Streaks)
+	weekDone := 0
+	for _, week := range resp.Weeks {
+		weekDone += week.Done
+	}
+	assert.Equal(t, 3, weekDone)
+
+	resp = stats("from=" + day(-7) + "&to=" + day(-1))
+	assert.Zero(t, resp.Done)
+	assert.Empty(t, resp.Streaks)
+
+	// Статистика пересчитывается после нового выполнения.
+	do(t, mux, http.MethodPost, "/api/task/done?id=3", nil, nil)
+	resp = stats("")
+	assert.Equal(t, 4, resp.Done)
+	assert.Zero(t, resp.Overdue)
+
+	for _, query := range []string{"from=" + day(1), "from=" + day(-400), "to=никогда"} {
+		rec, m := do(t, mux, http.MethodGet, "/api/stats?"+query, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.NotEmpty(t, m["error"], query)
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	done := func(date, repeat, doneAt string) *db.Revision {
+		return &db.Revision{TaskID: "7", Action: db.ActionDone, CreatedAt: doneAt + "T12:00:00Z",
+			Before: &db.Task{ID: "7", Title: "Полив", Date: date, Repeat: repeat}}
+	}
+	completions := []*db.Revision{
+		done("20261005", "d 1", "2026-10-05"),
+		done("20261006", "d 1", "2026-10-06"),
+		done("20261007", "d 1", "2026-10-07"),
+		done("20261008", "d 1", "2026-10-11"),
+		done("20261012", "d 1", "2026-10-12"),
+	}
+
+	stats := computeStats(completions, nil, "20261005", "20261012")
+	assert.Equal(t, []*Streak{{TaskID: "7", Title: "Полив", Current: 1, Longest: 3}}, stats.Streaks)
+	assert.Equal(t, []*WeekStats{{Week: "20261005", Done: 4, Late: 1}, {Week: "20261012", Done: 1}}, stats.Weeks)
+	assert.Equal(t, 0.6, stats.AvgLateness)
+
+	stats = computeStats(completions, []*db.Task{{ID: "7"}}, "20261005", "20261012")
+	assert.Equal(t, 0, stats.Streaks[0].Current, "просроченная задача прерывает серию")
+
+	// Серия, начавшаяся до from, считается целиком, а выполнения до from
+	// в остальную статистику не входят.
+	earlier := []*db.Revision{
+		done("20261003", "d 1", "2026-10-03"),
+		done("20261004", "d 1", "2026-10-04"),
+	}
+	stats = computeStats(append(earlier, completions...), nil, "20261005", "20261012")
+	assert.Equal(t, []*Streak{{TaskID: "7", Title: "Полив", Current: 1, Longest: 5}}, stats.Streaks)
+	assert.Equal(t, 5, stats.Done)
+	assert.Equal(t, 0.6, stats.AvgLateness)
+	stats = computeStats(append(earlier, completions[:3]...), nil, "20261005", "20261007")
+	assert.Equal(t, []*Streak{{TaskID: "7", Title: "Полив", Current: 5, Longest: 5}}, stats.Streaks)
+	stats = computeStats(earlier, nil, "20261005", "20261012")
+	assert.Empty(t, stats.Streaks, "задача не выполнялась за период")
+}
+
+func TestSavedSearches(t *testing.T) {
+	mux, store := newTestAPI(t)
+	_, err := store.AddTasks([]*db.Task{
+		{Date: "20240102", Title: "Отчёт +работа", Repeat: "m 2"},
+		{Date: "20240101", Title: "Созвон +работа"},
+		{Date: "20240103", Title: "Уборка +дом"},
+	})
+	require.NoError(t, err)
+
+	_, m := do(t, mux, http.MethodPost, "/api/search",
+		map[string]any{"name": " Работа ", "query": "tag:работа", "sort": "title"}, nil)
+	id, ok := m["id"].(string)
+	require.True(t, ok, m)
+
+	titles := func(target string) []string {
+		_, m := do(t, mux, http.MethodGet, target, nil, nil)
+		titles := []string{}
+		for _, task := range m["tasks"].([]any) {
+			titles = append(titles, task.(map[string]any)["title"].(string))
+		}
+		return titles
+	}
+	assert.Equal(t, []string{"Отчёт +работа", "Созвон +работа"}, titles("/api/tasks?saved="+id))
+	assert.Equal(t, []string{"Созвон +работа", "Отчёт +работа"}, titles("/api/tasks?saved="+id+"&sort=date"))
+	assert.Equal(t, []string{"Отчёт +работа"}, titles("/api/tasks?saved="+id+"&search=repeat:any"))
+
+	_, m = do(t, mux, http.MethodPut, "/api/search",
+		map[string]any{"id": id, "name": "Работа", "query": "tag:работа -отчёт"}, nil)
+	assert.Empty(t, m)
+	_, m = do(t, mux, http.MethodGet, "/api/search?id="+id, nil, nil)
+	assert.Equal(t, "Работа", m["name"])
+	assert.Equal(t, []string{"Созвон +работа"}, titles("/api/tasks?saved="+id))
+	_, m = do(t, mux, http.MethodGet, "/api/searches", nil, nil)
+	assert.Len(t, m["searches"], 1)
+
+	for _, body := range []map[string]any{
+		{"name": "", "query": "tag:дом"},
+		{"name": "Дом", "query": `"дом`},
+		{"name": "Дом", "sort": "random"},
+	} {
+		rec, m := do(t, mux, http.MethodPost, "/api/search", body, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.NotEmpty(t, m["error"], body)
+	}
+
+	rec, _ := do(t, mux, http.MethodGet, "/api/tasks?sort=random", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	_, m = do(t, mux, http.MethodDelete, "/api/search?id="+id, nil, nil)
+	assert.Empty(t, m)
+	rec, _ = do(t, mux, http.MethodGet, "/api/tasks?saved="+id, nil, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec, _ = do(t, mux, http.MethodPut, "/api/search", map[string]any{"id": id, "name": "Работа"}, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDoneRecurring(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: "20240101", Title: "Зарядка", Repeat: "d 3"})
+	require.NoError(t, err)
+	_, err = store.AddTask(&db.Task{Date: "20240101", Title: "Сдать отчёт"})
+	require.NoError(t, err)
+
+	_, m := do(t, mux, http.MethodPost, "/api/task/done?id=1", nil, nil)
+	assert.Empty(t, m)
+	_, m = do(t, mux, http.MethodPost, "/api/task/done?id=2", nil, nil)
+	assert.Empty(t, m)
+
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "20240104", task.Date)
+	assert.Equal(t, db.StatusTodo, task.Status)
+
+	task, err = store.GetTask("2")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusDone, task.Status)
+
+	_, m = do(t, mux, http.MethodPost, "/api/task/done?id=2", nil, nil)
+	assert.NotEmpty(t, m["error"])
+
+	_, m = do(t, mux, http.MethodGet, "/api/tasks", nil, nil)
+	assert.Len(t, m["tasks"], 1)
+	_, m = do(t, mux, http.MethodGet, "/api/tasks?status=all", nil, nil)
+	assert.Len(t, m["tasks"], 2)
+
+	_, m = do(t, mux, http.MethodGet, "/api/task/history?id=1", nil, nil)
+	assert.Len(t, m["revisions"], 2)
+}
+
+func TestSnoozeSchedule(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	start := time.Now().UTC().AddDate(0, 0, 1)
+	day := func(n int) string { return start.AddDate(0, 0, n).Format(dateFormat) }
+	_, err := store.AddTask(&db.Task{Date: day(0), Title: "Полить цветы", Repeat: "d 7"})
+	require.NoError(t, err)
+
+	_, m := do(t, mux, http.MethodPost, "/api/task/snooze", map[string]string{"id": "1", "by": "+1d"}, nil)
+	assert.Equal(t, day(1), m["date"])
+
+	// После отложенной даты повторы идут по прежнему расписанию.
+	_, m = do(t, mux, http.MethodGet, "/api/agenda?from="+day(0)+"&to="+day(14), nil, nil)
+	var busy []string
+	for _, d := range m["days"].([]any) {
+		if d := d.(map[string]any); len(d["tasks"].([]any)) > 0 {
+			busy = append(busy, d["date"].(string))
+		}
+	}
+	assert.Equal(t, []string{day(1), day(7), day(14)}, busy)
+
+	_, m = do(t, mux, http.MethodPost, "/api/task/done?id=1", nil, nil)
+	assert.Empty(t, m)
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, day(7), task.Date)
+	assert.Empty(t, task.Anchor)
+
+	// Новая дата через PUT задаёт расписание заново.
+	do(t, mux, http.MethodPost, "/api/task/snooze", map[string]string{"id": "1", "by": "+1d"}, nil)
+	task, err = store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, day(7), task.Anchor)
+	update := map[string]any{"id": "1", "date": day(9), "title": "Полить цветы", "repeat": "d 7"}
+	rec, _ := do(t, mux, http.MethodPut, "/api/task", update, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	do(t, mux, http.MethodPost, "/api/task/done?id=1", nil, nil)
+	task, err = store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, day(16), task.Date)
+}
+
+func TestStatusErrors(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: today(), Title: "Сдать отчёт"})
+	require.NoError(t, err)
+
+	rec, _ := do(t, mux, http.MethodPost, "/api/task/status", map[string]string{"id": "7", "status": db.StatusWaiting}, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec, _ = do(t, mux, http.MethodPost, "/api/task/status", map[string]string{"id": "1", "status": db.StatusDone}, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec, m := do(t, mux, http.MethodPost, "/api/task/status", map[string]string{"id": "1", "status": db.StatusWaiting}, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "cannot change status from done to waiting", m["error"])
+}
+
+func TestVersionConflict(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: today(), Title: "Позвонить"})
+	require.NoError(t, err)
+
+	update := map[string]any{"id": "1", "date": today(), "title": "Позвонить маме"}
+	rec, _ := do(t, mux, http.MethodPut, "/api/task", update, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	update["title"] = "Позвонить папе"
+	rec, m := do(t, mux, http.MethodPut, "/api/task", update, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	current, ok := m["task"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "Позвонить маме", current["title"])
+
+	t.Setenv("TODO_UNVERSIONED_WRITES", "")
+	for _, req := range []struct{ method, target string }{
+		{http.MethodDelete, "/api/task?id=1"},
+		{http.MethodPost, "/api/task/done?id=1"},
+	} {
+		rec, _ = do(t, mux, req.method, req.target, nil, nil)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code, req.target)
+	}
+	update["title"] = "Позвонить брату"
+	rec, _ = do(t, mux, http.MethodPut, "/api/task", update, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	rec, _ = do(t, mux, http.MethodPut, "/api/task", update, map[string]string{"If-Match": "*"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestActorOf(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/api/task", nil)
+	req.RemoteAddr = "10.0.0.5:41000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	// Без доверенных прокси заголовок подделывается любым клиентом.
+	t.Setenv("TODO_TRUSTED_PROXIES", "")
+	assert.Equal(t, "10.0.0.5", actorOf(req))
+
+	t.Setenv("TODO_TRUSTED_PROXIES", "192.168.0.1, 10.0.0.0/8")
+	assert.Equal(t, "203.0.113.7", actorOf(req))
+
+	// Первый адрес прислал клиент, второй дописал внешний прокси.
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.2, 192.168.0.1")
+	assert.Equal(t, "198.51.100.2", actorOf(req))
+
+	req.RemoteAddr = "198.51.100.9:41000"
+	assert.Equal(t, "198.51.100.9", actorOf(req))
+}
+
+func TestStatusVersions(t *testing.T) {
+	mux, store := newTestAPI(t)
+	t.Setenv("TODO_UNVERSIONED_WRITES", "")
+
+	for _, title := range []string{"Позвонить", "Написать письмо"} {
+		_, err := store.AddTask(&db.Task{Date: today(), Title: title})
+		require.NoError(t, err)
+	}
+
+	done := map[string]string{"id": "1", "status": db.StatusDone}
+	rec, _ := do(t, mux, http.MethodPost, "/api/task/status", done, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	rec, _ = do(t, mux, http.MethodPost, "/api/task/status", map[string]string{"id": "1", "status": db.StatusWaiting}, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec, _ = do(t, mux, http.MethodPost, "/api/task/status", done, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	done["version"] = "2"
+	rec, _ = do(t, mux, http.MethodPost, "/api/task/status", done, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	date := time.Now().UTC().AddDate(0, 0, 3).Format(dateFormat)
+	for _, op := range []map[string]any{
+		{"op": "status", "id": "2", "status": db.StatusDone},
+		{"op": "reschedule", "id": "2", "date": date},
+	} {
+		req := map[string]any{"ops": []map[string]any{op}}
+		_, m := do(t, mux, http.MethodPost, "/api/tasks/batch", req, nil)
+		results := m["results"].([]any)
+		assert.Equal(t, errPreconditionRequired.Error(), results[0].(map[string]any)["error"], op["op"])
+
+		op["version"] = "5"
+		_, m = do(t, mux, http.MethodPost, "/api/tasks/batch", req, nil)
+		results = m["results"].([]any)
+		assert.Equal(t, db.ErrConflict.Error(), results[0].(map[string]any)["error"], op["op"])
+	}
+
+	req := map[string]any{"atomic": true, "ops": []map[string]any{
+		{"op": "reschedule", "id": "2", "date": date, "version": "1"},
+		{"op": "status", "id": "2", "status": db.StatusDone, "version": "2"},
+	}}
+	_, m := do(t, mux, http.MethodPost, "/api/tasks/batch", req, nil)
+	assert.Equal(t, true, m["committed"])
+	task, err := store.GetTask("2")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusDone, task.Status)
+	assert.Equal(t, date, task.Date)
+}
+
+func TestBatchAtomic(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: today(), Title: "Полить цветы"})
+	require.NoError(t, err)
+
+	req := map[string]any{
+		"atomic": true,
+		"ops": []map[string]any{
+			{"op": "delete", "id": "1"},
+			{"op": "done", "id": "100"},
+		},
+	}
+	_, m := do(t, mux, http.MethodPost, "/api/tasks/batch", req, nil)
+	assert.Equal(t, false, m["committed"])
+
+	_, err = store.GetTask("1")
+	assert.NoError(t, err)
+
+	req["atomic"] = false
+	_, m = do(t, mux, http.MethodPost, "/api/tasks/batch", req, nil)
+	assert.Equal(t, true, m["committed"])
+
+	_, err = store.GetTask("1")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func TestBatchUpdateKeepsDate(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: "20240101", Title: "Сдать отчёт"})
+	require.NoError(t, err)
+
+	title := "Сдать годовой отчёт"
+	req := map[string]any{"ops": []map[string]any{{"op": "update", "id": "1", "title": title}}}
+	_, m := do(t, mux, http.MethodPost, "/api/tasks/batch", req, nil)
+	assert.Equal(t, true, m["committed"])
+
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, title, task.Title)
+	assert.Equal(t, "20240101", task.Date)
+
+	req = map[string]any{"ops": []map[string]any{{"op": "update", "id": "1", "repeat": "d 7"}}}
+	_, m = do(t, mux, http.MethodPost, "/api/tasks/batch", req, nil)
+	assert.Equal(t, true, m["committed"])
+	task, err = store.GetTask("1")
+	require.NoError(t, err)
+	assert.Greater(t, task.Date, "20240101")
+}
+
+func TestBackupUnsupported(t *testing.T) {
+	mux, _ := newTestAPI(t)
+
+	rec, m := do(t, mux, http.MethodGet, "/api/backup", nil, nil)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	assert.Equal(t, db.ErrBackupUnsupported.Error(), m["error"])
+}
+
+func TestExportImport(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: "20250101", Title: "Зарядка", Comment: "утром, \"бодро\"", Repeat: "d 1"})
+	require.NoError(t, err)
+	_, err = store.AddTask(&db.Task{Date: "20250102", Title: "Отчёт"})
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateStatus("2", db.StatusWaiting))
+
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/export?format="+format, nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			target, _ := newTestAPI(t)
+			req = httptest.NewRequest(http.MethodPost, "/api/import?mode=replace&format="+format, rec.Body)
+			rec = httptest.NewRecorder()
+			target.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			_, m := do(t, target, http.MethodGet, "/api/tasks?status=all", nil, nil)
+			tasks, ok := m["tasks"].([]any)
+			require.True(t, ok)
+			require.Len(t, tasks, 2)
+			first := tasks[0].(map[string]any)
+			assert.Equal(t, "20250101", first["date"])
+			assert.Equal(t, "утром, \"бодро\"", first["comment"])
+			assert.Equal(t, "d 1", first["repeat"])
+			assert.Equal(t, db.StatusWaiting, tasks[1].(map[string]any)["status"])
+		})
+	}
+}
+
+func TestImportReplaceKeepsIDs(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	for _, title := range []string{"Старая 1", "Старая 2", "Старая 3"} {
+		_, err := store.AddTask(&db.Task{Date: "20250101", Title: title})
+		require.NoError(t, err)
+	}
+
+	rec, m := do(t, mux, http.MethodPost, "/api/import?mode=replace", []map[string]any{
+		{"id": "2", "date": "20250102", "title": "Отчёт", "status": db.StatusWaiting},
+		{"id": "7", "date": "20250103", "title": "Зарядка", "repeat": "d 1"},
+		{"date": "20250104", "title": "Без id"},
+	}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, m)
+
+	_, m = do(t, mux, http.MethodGet, "/api/tasks?status=all", nil, nil)
+	tasks, ok := m["tasks"].([]any)
+	require.True(t, ok)
+	require.Len(t, tasks, 3)
+	ids := make(map[string]string)
+	for _, task := range tasks {
+		task := task.(map[string]any)
+		ids[task["title"].(string)] = task["id"].(string)
+	}
+	assert.Equal(t, "2", ids["Отчёт"])
+	assert.Equal(t, "7", ids["Зарядка"])
+	// Задача без id получает новый, не совпадающий с загруженными.
+	assert.Equal(t, "8", ids["Без id"])
+
+	task, err := store.GetTask("2")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusWaiting, task.Status)
+
+	id, err := store.AddTask(&db.Task{Date: "20250105", Title: "Новая"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), id)
+}
+
+func TestImportMergeStatus(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: "20250102", Title: "Отчёт"})
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateStatus("1", db.StatusInProgress))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export?format=json", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	exported := rec.Body.String()
+
+	require.NoError(t, store.CompleteTask("1", 0, func(*db.Task) (string, error) { return "", nil }))
+
+	// Статус восстанавливается из выгрузки, хотя из done в in_progress
+	// задачу перевести нельзя.
+	req = httptest.NewRequest(http.MethodPost, "/api/import?mode=merge&format=json", strings.NewReader(exported))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusInProgress, task.Status)
+	assert.Equal(t, "Отчёт", task.Title)
+	assert.Equal(t, int64(4), task.Version)
+}
+
+func TestImportErrors(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: "20240101", Title: "Старая"})
+	require.NoError(t, err)
+
+	rec, m := do(t, mux, http.MethodPost, "/api/import", []map[string]any{
+		{"id": "1", "date": "20240105", "title": "Обновлённая"},
+		{"date": "2024-01-32", "title": "Неверная дата"},
+		{"title": ""},
+		{"date": "20240105", "title": "Неверный повтор", "repeat": "x 5"},
+	}, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	errs, ok := m["errors"].([]any)
+	require.True(t, ok)
+	require.Len(t, errs, 3)
+	assert.Equal(t, float64(2), errs[0].(map[string]any)["row"])
+
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Старая", task.Title)
+
+	_, m = do(t, mux, http.MethodPost, "/api/import", []map[string]any{
+		{"id": "1", "date": "2024-01-05", "title": "Обновлённая"},
+		{"title": "Новая"},
+	}, nil)
+	assert.Equal(t, float64(2), m["imported"])
+
+	task, err = store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Обновлённая", task.Title)
+	assert.Equal(t, "20240105", task.Date)
+	task, err = store.GetTask("2")
+	require.NoError(t, err)
+	assert.Equal(t, today(), task.Date)
+}
+
+func TestCalendarFeed(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	_, err := store.AddTask(&db.Task{Date: today(), Title: "Планёрка", Repeat: "w 1,3"})
+	require.NoError(t, err)
+	_, err = store.AddTask(&db.Task{Date: "20280229", Title: "Високосный день", Repeat: "y"})
+	require.NoError(t, err)
+	done, err := store.AddTask(&db.Task{Date: today(), Title: "Сделано"})
+	require.NoError(t, err)
+	err = store.UpdateStatus(strconv.FormatInt(done, 10), db.StatusDone)
+	require.NoError(t, err)
+
+	_, m := do(t, mux, http.MethodPost, "/api/feed", map[string]any{"name": "Работа"}, nil)
+	token, ok := m["token"].(string)
+	require.True(t, ok, m)
+	assert.Contains(t, m["url"], "/api/calendar.ics?token="+token)
+
+	_, m = do(t, mux, http.MethodGet, "/api/feeds", nil, nil)
+	feeds := m["feeds"].([]any)
+	require.Len(t, feeds, 1)
+	assert.Equal(t, db.FeedEvents, feeds[0].(map[string]any)["kind"])
+	assert.NotContains(t, feeds[0], "token_hash")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/calendar.ics?token=" + token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "SUMMARY:Планёрка\r\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE\r\n")
+	assert.Contains(t, body, "UID:task-2-20280229@taskmanager\r\n")
+	assert.NotContains(t, body, "Сделано")
+
+	assert.Equal(t, http.StatusUnauthorized, get("/api/calendar.ics").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/calendar.ics?token=wrong").Code)
+
+	_, m = do(t, mux, http.MethodDelete, "/api/feed?id="+feeds[0].(map[string]any)["id"].(string), nil, nil)
+	assert.Empty(t, m)
+	assert.Equal(t, http.StatusNotFound, get("/api/calendar.ics?token="+token).Code)
+}
+
+func TestImportICS(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	soon := time.Now().UTC().AddDate(0, 0, 3).Format(dateFormat)
+	calendar := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nUID:a@example.com\r\nDTSTART;VALUE=DATE:" + soon + "\r\nSUMMARY:Планёрка\r\n" +
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:a@example.com\r\nRECURRENCE-ID:" + soon + "\r\nDTSTART:" + soon + "\r\nSUMMARY:Планёрка\r\nEND:VEVENT\r\n" +
+		"BEGIN:VTODO\r\nUID:b@example.com\r\nDUE;VALUE=DATE:" + soon + "\r\nSUMMARY:Отчёт\r\n" +
+		"DESCRIPTION:за квартал\r\nRRULE:FREQ=MONTHLY;BYDAY=-1FR\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:c@example.com\r\nSUMMARY:Отменено\r\nSTATUS:CANCELLED\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	post := func() map[string]any {
+		req := httptest.NewRequest(http.MethodPost, "/api/import/ics", bytes.NewReader([]byte(calendar)))
+		req.Header.Set("Content-Type", "text/calendar")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		return m
+	}
+
+	m := post()
+	assert.Equal(t, float64(2), m["imported"])
+	assert.Equal(t, float64(2), m["skipped"])
+	assert.Equal(t, float64(0), m["duplicates"])
+	assert.Len(t, m["notes"], 4)
+
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Планёрка", task.Title)
+	assert.Equal(t, "w 1,4", task.Repeat)
+	task, err = store.GetTask("2")
+	require.NoError(t, err)
+	assert.Equal(t, soon, task.Date)
+	assert.Equal(t, "за квартал", task.Comment)
+	assert.Empty(t, task.Repeat)
+
+	m = post()
+	assert.Equal(t, float64(0), m["imported"])
+	assert.Equal(t, float64(2), m["duplicates"])
+
+	rec, _ := do(t, mux, http.MethodPost, "/api/import/ics", "not a calendar", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	for _, task := range []*db.Task{
+		{Date: "20240105", Title: "(A) Позвонить +семья @телефон", Repeat: "d 14"},
+		{Date: "20240110", Title: "Отчёт", Repeat: "m 1,-1 3,6,9,12", Status: db.StatusWaiting},
+		{Date: "20240101", Title: "Сдать книги", Status: db.StatusDone},
+	} {
+		_, err := store.AddTask(task)
+		require.NoError(t, err)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/export?format=todotxt", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	exported := rec.Body.String()
+	assert.Equal(t, "x Сдать книги due:2024-01-01\n"+
+		"(A) Позвонить +семья @телефон due:2024-01-05 rec:2w\n"+
+		"Отчёт due:2024-01-10 repeat:m_1,-1_3,6,9,12 status:waiting\n", exported)
+
+	target, other := newTestAPI(t)
+	_, m := do(t, target, http.MethodPost, "/api/import/todotxt", nil, nil)
+	assert.Equal(t, float64(0), m["imported"])
+
+	req := httptest.NewRequest(http.MethodPost, "/api/import/todotxt",
+		strings.NewReader(exported+"\nНеверно due:завтра\n"))
+	rec = httptest.NewRecorder()
+	target.ServeHTTP(rec, req)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+	assert.Equal(t, float64(3), m["imported"])
+	assert.Equal(t, float64(1), m["skipped"])
+	assert.Len(t, m["notes"], 1)
+
+	var before, after []db.Task
+	for _, s := range []db.Store{store, other} {
+		var tasks []db.Task
+		require.NoError(t, s.EachTask(func(task *db.Task) error {
+			tasks = append(tasks, db.Task{Date: task.Date, Title: task.Title, Repeat: task.Repeat, Status: task.Status})
+			return nil
+		}))
+		before, after = after, tasks
+	}
+	assert.Equal(t, before, after)
+}
+
+func TestImportTodoistTrello(t *testing.T) {
+	mux, store := newTestAPI(t)
+
+	soon := time.Now().UTC().AddDate(0, 0, 2)
+	todoistExport := `{
+		"projects": [{"id": "1", "name": "Inbox", "inbox_project": true}, {"id": "2", "name": "Дом и сад"}],
+		"items": [
+			{"id": "10", "content": "Уборка", "project_id": "2", "labels": ["выходные"],
+			 "description": "Вся квартира",
+			 "due": {"date": "` + soon.Format("2006-01-02") + `", "string": "every 2 weeks at 10am", "is_recurring": true}},
+			{"id": "11", "content": "Пропылесосить", "parent_id": "10", "checked": true},
+			{"id": "12", "content": "Отчёт", "project_id": "1",
+			 "due": {"date": "` + soon.Format("2006-01-02") + `", "string": "every first monday", "is_recurring": true}}
+		]
+	}`
+
+	post := func(target, body string) map[string]any {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		return m
+	}
+
+	// Пробный запуск показывает задачи, но ничего не записывает.
+	m := post("/api/import/todoist?dry_run=1", todoistExport)
+	assert.Equal(t, true, m["dry_run"])
+	assert.Equal(t, float64(2), m["imported"])
+	assert.Len(t, m["notes"], 2)
+	tasks := m["tasks"].([]any)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "Уборка +Дом_и_сад @выходные", tasks[0].(map[string]any)["title"])
+	assert.Equal(t, "d 14", tasks[0].(map[string]any)["repeat"])
+	assert.Equal(t, "Вся квартира\n\n- [x] Пропылесосить", tasks[0].(map[string]any)["comment"])
+	assert.Equal(t, "", tasks[1].(map[string]any)["repeat"])
+	_, err := store.GetTask("1")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	m = post("/api/import/todoist", todoistExport)
+	assert.Equal(t, float64(2), m["imported"])
+	assert.NotContains(t, m, "tasks")
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, soon.Format(dateFormat), task.Date)
+
+	m = post("/api/import/todoist", todoistExport)
+	assert.Equal(t, float64(0), m["imported"])
+	assert.Equal(t, float64(2), m["duplicates"])
+
+	trelloExport := `{
+		"lists": [{"id": "l1", "name": "Ремонт"}],
+		"labels": [{"id": "b1", "name": "срочно"}],
+		"cards": [
+			{"id": "c1", "name": "Покрасить стены", "desc": "Белая краска", "idList": "l1", "idLabels": ["b1"],
+			 "due": "` + soon.Format("2006-01-02") + `T09:00:00.000Z", "dueComplete": true},
+			{"id": "c2", "name": "Старая карточка", "idList": "l1", "closed": true}
+		],
+		"checklists": [{"id": "k1", "idCard": "c1", "name": "Материалы",
+			"checkItems": [{"name": "Краска", "state": "complete"}, {"name": "Валик", "state": "incomplete"}]}]
+	}`
+	m = post("/api/import/trello", trelloExport)
+	assert.Equal(t, float64(1), m["imported"])
+	assert.Equal(t, float64(1), m["skipped"])
+
+	task, err = store.GetTask("3")
+	require.NoError(t, err)
+	assert.Equal(t, "Покрасить стены +Ремонт @срочно", task.Title)
+	assert.Equal(t, "Белая краска\n\nМатериалы:\n- [x] Краска\n- [ ] Валик", task.Comment)
+	assert.Equal(t, db.StatusDone, task.Status)
+
+	rec, _ := do(t, mux, http.MethodPost, "/api/import/trello", map[string]any{"items": []any{}}, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
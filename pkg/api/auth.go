@@ -4,8 +4,11 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -70,6 +73,70 @@ func signInHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, SignInResponse{Token: tokenString})
 }
 
+// actorOf определяет автора изменений для истории задач. Учётных записей
+// в приложении нет, поэтому автором считается адрес клиента. X-Forwarded-For
+// может прислать любой клиент, поэтому ему верят, только если запрос пришёл
+// от прокси из TODO_TRUSTED_PROXIES, и берут адрес, который дописал
+// последний доверенный прокси, а не присланный клиентом первый.
+func actorOf(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	proxies := trustedProxies()
+	if !isTrusted(host, proxies) {
+		return host
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		host = hop
+		if !isTrusted(hop, proxies) {
+			break
+		}
+	}
+	return host
+}
+
+// trustedProxies разбирает TODO_TRUSTED_PROXIES: адреса и подсети прокси
+// через запятую, например "127.0.0.1,10.0.0.0/8". Неверные записи пропускаются.
+func trustedProxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv("TODO_TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
+func isTrusted(host string, proxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 func auth(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Проверяем, установлен ли пароль
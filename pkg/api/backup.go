@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BackupHandler отдаёт согласованный снимок базы, сделанный без остановки сервера.
+func (a *API) BackupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, ok := a.store.(db.Backuper)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		writeError(w, db.ErrBackupUnsupported)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "scheduler-backup-")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scheduler.db")
+	if err := b.Backup(path); err != nil {
+		if errors.Is(err, db.ErrBackupUnsupported) {
+			w.WriteHeader(http.StatusNotImplemented)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		writeError(w, err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+
+	name := fmt.Sprintf("scheduler-%s.db", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	io.Copy(w, f)
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"go1f/pkg/db"
+	"net/http"
+	"time"
+)
+
+// maxBatchOps ограничивает число операций в одном пакетном запросе.
+const maxBatchOps = 500
+
+// BatchOp — одна операция пакетного запроса.
+// Для reschedule задаётся date или by (как в /api/task/snooze),
+// для update — изменяемые поля задачи. Ненулевая version проверяется
+// так же, как в /api/task, а без неё любая операция отклоняется, если
+// версия обязательна (см. versionRequired).
+type BatchOp struct {
+	Op      string  `json:"op"`
+	ID      string  `json:"id"`
+	Date    string  `json:"date"`
+	By      string  `json:"by"`
+	Title   *string `json:"title"`
+	Comment *string `json:"comment"`
+	Repeat  *string `json:"repeat"`
+	Status  string  `json:"status"`
+	Version int64   `json:"version,string"`
+}
+
+type BatchReq struct {
+	Atomic bool      `json:"atomic"`
+	Ops    []BatchOp `json:"ops"`
+}
+
+type BatchResult struct {
+	ID    string `json:"id"`
+	Op    string `json:"op"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BatchResp struct {
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchHandler выполняет список операций над задачами в одной транзакции.
+// В режиме atomic первая же ошибка откатывает все изменения; иначе
+// откатывается только неудачная операция, а остальные фиксируются.
+func (a *API) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BatchReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error reading JSON: %v", err)})
+		return
+	}
+
+	if len(req.Ops) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "no operations"})
+		return
+	}
+	if len(req.Ops) > maxBatchOps {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("too many operations, max %d", maxBatchOps)})
+		return
+	}
+
+	tx, err := a.repo(r).Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	resp := BatchResp{Results: make([]BatchResult, len(req.Ops))}
+	failed := false
+	for i, op := range req.Ops {
+		res := &resp.Results[i]
+		res.ID, res.Op = op.ID, op.Op
+		if failed {
+			res.Error = "not executed"
+			continue
+		}
+
+		if err := runBatchOp(tx, op, req.Atomic, fmt.Sprintf("op%d", i)); err != nil {
+			res.Error = err.Error()
+			failed = req.Atomic
+			continue
+		}
+		res.OK = true
+	}
+
+	if failed {
+		tx.Rollback()
+		for i := range resp.Results {
+			resp.Results[i].OK = false
+		}
+		writeJSON(w, resp)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	resp.Committed = true
+	writeJSON(w, resp)
+}
+
+// runBatchOp выполняет операцию. Вне атомарного режима каждая операция
+// обёрнута в точку сохранения, чтобы её ошибка не задела остальные.
+func runBatchOp(tx db.Tx, op BatchOp, atomic bool, savepoint string) error {
+	if atomic {
+		return applyBatchOp(tx, op)
+	}
+
+	if err := tx.Savepoint(savepoint); err != nil {
+		return err
+	}
+	if err := applyBatchOp(tx, op); err != nil {
+		if rbErr := tx.RollbackTo(savepoint); rbErr != nil {
+			return rbErr
+		}
+		tx.Release(savepoint)
+		return err
+	}
+	return tx.Release(savepoint)
+}
+
+func applyBatchOp(tx db.Store, op BatchOp) error {
+	if op.ID == "" {
+		return fmt.Errorf("Не указан идентификатор")
+	}
+
+	task, err := tx.GetTask(op.ID)
+	if err != nil {
+		return err
+	}
+
+	if op.Version == 0 && versionRequired() {
+		return errPreconditionRequired
+	}
+
+	switch op.Op {
+	case "done":
+		return tx.CompleteTask(task.ID, op.Version, nextTaskDate)
+	case "delete":
+		return tx.DeleteTask(task.ID, op.Version)
+	case "status":
+		if !db.ValidStatus(op.Status) {
+			return fmt.Errorf("unknown task status")
+		}
+		return changeStatus(tx, task.ID, op.Status, op.Version)
+	case "reschedule":
+		if (op.By == "") == (op.Date == "") {
+			return fmt.Errorf("either by or date is required")
+		}
+		if op.Version != 0 && op.Version != task.Version {
+			return db.ErrConflict
+		}
+		next, err := snoozeDate(task, SnoozeReq{By: op.By, Date: op.Date}, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		return tx.UpdateDate(next, task.ID)
+	case "update":
+		if op.Date != "" {
+			task.Date = op.Date
+		}
+		if op.Title != nil {
+			task.Title = *op.Title
+		}
+		if op.Comment != nil {
+			task.Comment = *op.Comment
+		}
+		if op.Repeat != nil {
+			task.Repeat = *op.Repeat
+		}
+		if task.Title == "" {
+			return fmt.Errorf("task title is required")
+		}
+		// Прошедшая дата переносится, только если её или правило меняют:
+		// правка названия не должна сдвигать просроченную задачу.
+		if op.Date != "" || op.Repeat != nil {
+			if err := checkDate(task); err != nil {
+				return err
+			}
+		}
+		task.Version = op.Version
+		return tx.UpdateTask(task)
+	}
+	return fmt.Errorf("unknown operation %q", op.Op)
+}
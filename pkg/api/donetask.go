@@ -1,12 +1,15 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"go1f/pkg/db"
 	"go1f/pkg/nextdate"
 	"net/http"
+	"time"
 )
 
-func DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
@@ -18,26 +21,45 @@ func DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := db.GetTask(id)
+	version, err := requestVersion(r, 0)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+
+	err = a.repo(r).CompleteTask(id, version, nextTaskDate)
+	if errors.Is(err, db.ErrConflict) {
+		a.writeConflict(w, id)
+		return
+	}
 	if err != nil {
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
 
+	writeJSON(w, map[string]interface{}{})
+}
+
+// nextTaskDate возвращает дату, на которую переносится выполненная задача.
+// Разовая задача остаётся в базе завершённой (пустая дата), а повторяющаяся
+// переносится на следующую дату и снова ждёт выполнения. Для отложенной
+// задачи это первый повтор её прежнего расписания после даты переноса.
+func nextTaskDate(task *db.Task) (string, error) {
 	if task.Repeat == "" {
-		// Для разовых задач - удаляем их
-		if err := db.DeleteTask(id); err != nil {
-			writeJSON(w, map[string]string{"error": err.Error()})
-			return
-		}
-	} else {
-		// Для повторяющихся задач - вычисляем следующую дату и обновляем
-		next := nextdate.NextDate(task.Date, task.Repeat)
-		if err := db.UpdateDate(next, id); err != nil {
-			writeJSON(w, map[string]string{"error": err.Error()})
-			return
+		return "", nil
+	}
+	if task.Anchor != "" {
+		day, err := time.Parse(dateFormat, task.Date)
+		if err != nil {
+			return "", err
 		}
+		return NextDate(day, task.Anchor, task.Repeat)
 	}
 
-	writeJSON(w, map[string]interface{}{})
+	// Для повторяющихся задач - вычисляем следующую дату
+	next := nextdate.NextDate(task.Date, task.Repeat)
+	if next == "" {
+		return "", fmt.Errorf("could not calculate next date")
+	}
+	return next, nil
 }
@@ -0,0 +1,337 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go1f/pkg/dates"
+	"go1f/pkg/db"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// csvColumns — колонки CSV при экспорте задач. При импорте порядок колонок
+// берётся из заголовка, а обязательна только title.
+var csvColumns = []string{"id", "date", "title", "comment", "repeat", "status"}
+
+const maxImportSize = 10 << 20
+
+// errImportRows прерывает транзакцию импорта, если строку не удалось записать.
+var errImportRows = errors.New("some rows could not be imported")
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportResp struct {
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// ExportHandler выгружает все задачи, включая выполненные, в JSON, CSV
+// или todo.txt.
+func (a *API) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.json"`)
+		err = exportJSON(w, a.store)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+		err = exportCSV(w, a.store)
+	case "todotxt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="todo.txt"`)
+		err = ExportTodoTxt(w, a.store)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("unknown export format %q", format)})
+		return
+	}
+
+	// Часть ответа уже отправлена, поэтому сообщить об ошибке клиенту нельзя.
+	if err != nil {
+		log.Printf("error exporting tasks: %v", err)
+	}
+}
+
+// exportJSON пишет задачи по одной, не собирая весь список в памяти.
+func exportJSON(w io.Writer, store db.Store) error {
+	if _, err := io.WriteString(w, `{"tasks":[`); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	sep := ""
+	err := store.EachTask(func(task *db.Task) error {
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		sep = ","
+		return enc.Encode(task)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+func exportCSV(w io.Writer, store db.Store) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	err := store.EachTask(func(task *db.Task) error {
+		return cw.Write([]string{task.ID, task.Date, task.Title, task.Comment, task.Repeat, task.Status})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportHandler загружает задачи из JSON или CSV в одной транзакции.
+// В режиме merge задачи с известным id обновляются, остальные добавляются;
+// в режиме replace все задачи сначала удаляются, а загруженные сохраняют
+// свои id. Если хотя бы одна строка
+// не прошла проверку, ничего не записывается, а в ответе перечислены ошибки.
+func (a *API) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("unknown import mode %q", mode)})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		format = "csv"
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	var tasks []*db.Task
+	var err error
+	switch format {
+	case "", "json":
+		tasks, err = decodeJSONTasks(body)
+	case "csv":
+		tasks, err = decodeCSVTasks(body)
+	default:
+		err = fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var resp ImportResp
+	for i, task := range tasks {
+		if err := checkImported(task); err != nil {
+			resp.Errors = append(resp.Errors, ImportRowError{Row: i + 1, Error: err.Error()})
+		}
+	}
+	if len(resp.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		writeJSON(w, resp)
+		return
+	}
+
+	err = a.repo(r).InTx(func(tx db.Store) error {
+		resp.Errors = importTasks(tx, tasks, mode == "replace")
+		if len(resp.Errors) > 0 {
+			return errImportRows
+		}
+		return nil
+	})
+	if errors.Is(err, errImportRows) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		writeJSON(w, resp)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	resp.Imported = len(tasks)
+	writeJSON(w, resp)
+}
+
+// checkImported проверяет задачу по тем же правилам, что и при добавлении
+// (checkDate), но прошедшую дату не переносит: импортируемые задачи
+// сохраняются такими, какими были выгружены.
+func checkImported(task *db.Task) error {
+	if task.Title == "" {
+		return errors.New("task title is required")
+	}
+	if task.Status != "" && !db.ValidStatus(task.Status) {
+		return fmt.Errorf("unknown task status %q", task.Status)
+	}
+
+	checked := *task
+	if err := checkDate(&checked); err != nil {
+		return err
+	}
+	if task.Date == "" {
+		task.Date = checked.Date
+	} else {
+		// Дату уже проверил checkDate, остаётся привести её к YYYYMMDD.
+		task.Date, _ = dates.Normalize(task.Date, time.Now().UTC())
+	}
+	if task.Repeat == "" {
+		task.Anchor = ""
+	} else if _, err := time.Parse(dateFormat, task.Anchor); task.Anchor != "" && err != nil {
+		return fmt.Errorf("invalid anchor %q", task.Anchor)
+	}
+	return nil
+}
+
+// importTasks записывает задачи и возвращает ошибки отдельных строк.
+func importTasks(tx db.Store, tasks []*db.Task, replace bool) []ImportRowError {
+	var errs []ImportRowError
+	fail := func(row int, err error) {
+		errs = append(errs, ImportRowError{Row: row, Error: err.Error()})
+	}
+
+	if replace {
+		var ids []string
+		err := tx.EachTask(func(task *db.Task) error {
+			ids = append(ids, task.ID)
+			return nil
+		})
+		for _, id := range ids {
+			if err == nil {
+				err = tx.DeleteTask(id, 0)
+			}
+		}
+		if err != nil {
+			fail(0, err)
+			return errs
+		}
+	}
+
+	for i, task := range tasks {
+		if err := importTask(tx, task, replace); err != nil {
+			fail(i+1, err)
+		}
+	}
+	return errs
+}
+
+func importTask(tx db.Store, task *db.Task, replace bool) error {
+	if replace && task.ID != "" {
+		// После очистки хранилища задачи создаются заново с прежними
+		// идентификаторами, чтобы ссылки на них остались верными.
+		if task.Status == "" {
+			task.Status = db.StatusTodo
+		}
+		task.Version = 0
+		return tx.RestoreTask(task)
+	}
+	if task.ID != "" {
+		current, err := tx.GetTask(task.ID)
+		if err == nil {
+			// Импорт восстанавливает данные, а не меняет статус по правилам
+			// работы с задачей, поэтому статус пишется вместе с остальными полями.
+			if task.Status == "" {
+				task.Status = current.Status
+			}
+			task.Version = current.Version
+			return tx.RestoreTask(task)
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+	}
+
+	_, err := tx.AddTask(task)
+	return err
+}
+
+// decodeJSONTasks принимает как ответ экспорта ({"tasks": [...]}), так и массив задач.
+func decodeJSONTasks(r io.Reader) ([]*db.Task, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*db.Task
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &tasks)
+	} else {
+		var resp TasksResp
+		err = json.Unmarshal(data, &resp)
+		tasks = resp.Tasks
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON: %v", err)
+	}
+	return tasks, nil
+}
+
+func decodeCSVTasks(r io.Reader) ([]*db.Task, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %v", err)
+	}
+
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["title"]; !ok {
+		return nil, errors.New("CSV header must contain a title column")
+	}
+
+	var tasks []*db.Task
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %v", err)
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		tasks = append(tasks, &db.Task{
+			ID:      field("id"),
+			Date:    field("date"),
+			Title:   field("title"),
+			Comment: field("comment"),
+			Repeat:  field("repeat"),
+			Status:  field("status"),
+		})
+	}
+	return tasks, nil
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"go1f/pkg/ical"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// feedWindow — на сколько дней вперёд разворачиваются повторы задач,
+// правило которых нельзя записать в RRULE.
+const feedWindow = 90
+
+type FeedsResp struct {
+	Feeds []*db.Feed `json:"feeds"`
+}
+
+type AddFeedReq struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// AddFeedResp возвращает токен ленты. Он показывается только один раз:
+// в базе хранится лишь его хеш.
+type AddFeedResp struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// FeedHandler создаёт и удаляет ленты календаря.
+func (a *API) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.addFeedHandler(w, r)
+	case http.MethodDelete:
+		a.deleteFeedHandler(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) FeedsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feeds, err := a.repo(r).Feeds()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, FeedsResp{Feeds: feeds})
+}
+
+func (a *API) addFeedHandler(w http.ResponseWriter, r *http.Request) {
+	var req AddFeedReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error reading JSON: %v", err)})
+		return
+	}
+
+	if req.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "feed name is required"})
+		return
+	}
+	switch req.Kind {
+	case "":
+		req.Kind = db.FeedEvents
+	case db.FeedEvents, db.FeedTodos:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("unknown feed kind %q", req.Kind)})
+		return
+	}
+
+	token, err := newFeedToken()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	feed := &db.Feed{Name: req.Name, Kind: req.Kind, TokenHash: hashFeedToken(token)}
+	id, err := a.repo(r).AddFeed(feed)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error adding feed: %v", err)})
+		return
+	}
+
+	writeJSON(w, AddFeedResp{
+		ID:    strconv.FormatInt(id, 10),
+		Token: token,
+		URL:   feedURL(r, token),
+	})
+}
+
+func (a *API) deleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	if err := a.repo(r).DeleteFeed(id); err != nil {
+		if errors.Is(err, db.ErrFeedNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{})
+}
+
+// CalendarHandler отдаёт ленту незавершённых задач в формате iCalendar.
+// Календарные клиенты не передают cookie с JWT, поэтому лента доступна
+// без входа, но только по секретному токену.
+func (a *API) CalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusUnauthorized)
+		return
+	}
+
+	feed, err := a.store.FeedByToken(hashFeedToken(token))
+	if errors.Is(err, db.ErrFeedNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	var entries []ical.Entry
+	err = a.store.EachTask(func(task *db.Task) error {
+		if slices.Contains(db.ActiveStatuses, task.Status) {
+			entries = append(entries, taskEntries(task, feed.Kind, now)...)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	ical.Write(w, feed.Name, entries, now)
+}
+
+// taskEntries переводит задачу в записи календаря. Правило повторения
+// передаётся как RRULE, а если у него нет аналога — повторы разворачиваются
+// на feedWindow дней вперёд.
+func taskEntries(task *db.Task, kind string, now time.Time) []ical.Entry {
+	date, err := time.Parse(dateFormat, task.Date)
+	if err != nil {
+		return nil
+	}
+
+	entry := ical.Entry{
+		UID:         "task-" + task.ID + "@taskmanager",
+		Summary:     task.Title,
+		Description: task.Comment,
+		Date:        date,
+		Todo:        kind == db.FeedTodos,
+	}
+	if entry.Todo {
+		entry.Status = "NEEDS-ACTION"
+		if task.Status == db.StatusInProgress {
+			entry.Status = "IN-PROCESS"
+		}
+	}
+	if task.Repeat == "" {
+		return []ical.Entry{entry}
+	}
+	// RRULE отсчитывается от DTSTART, поэтому повторы отложенной задачи
+	// разворачиваются, чтобы они шли по прежнему расписанию.
+	if rule, ok := ical.RRule(task.Repeat, date); ok && task.Anchor == "" {
+		entry.RRule = rule
+		return []ical.Entry{entry}
+	}
+
+	// Ближайшая дата задачи попадает в ленту, даже если она за пределами окна.
+	end := now.AddDate(0, 0, feedWindow).Format(dateFormat)
+	var entries []ical.Entry
+	for next := task.Date; len(entries) == 0 || next <= end; {
+		occ := entry
+		occ.UID = "task-" + task.ID + "-" + next + "@taskmanager"
+		occ.Date, _ = time.Parse(dateFormat, next)
+		entries = append(entries, occ)
+
+		if next, err = NextDate(occ.Date, repeatBase(task, next), task.Repeat); err != nil {
+			break
+		}
+	}
+	return entries
+}
+
+func newFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashFeedToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// feedURL возвращает адрес ленты, который нужно добавить в календарь.
+func feedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/calendar.ics?token=%s", scheme, r.Host, token)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"go1f/pkg/db"
+	"net/http"
+)
+
+type HistoryResp struct {
+	Revisions []*db.Revision `json:"revisions"`
+}
+
+type RevertReq struct {
+	ID       string `json:"id"`
+	Revision string `json:"revision"`
+}
+
+// HistoryHandler возвращает историю изменений задачи.
+func (a *API) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "id is required"})
+		return
+	}
+
+	revisions, err := a.repo(r).History(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, HistoryResp{Revisions: revisions})
+}
+
+// RevertTaskHandler возвращает задачу к состоянию из выбранной ревизии.
+func (a *API) RevertTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RevertReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid data format"})
+		return
+	}
+
+	if req.ID == "" || req.Revision == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "id and revision are required"})
+		return
+	}
+
+	task, err := a.repo(r).RevertTask(req.ID, req.Revision)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, task)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"go1f/pkg/db"
+	"go1f/pkg/ical"
+	"io"
+	"strings"
+	"time"
+)
+
+// parseICS читает записи VEVENT и VTODO календаря. Правило повторения,
+// исключения из него и изменённые повторения, которые перенести нельзя,
+// перечисляются в заметках к записи.
+func parseICS(r io.Reader) ([]importItem, error) {
+	entries, err := ical.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]importItem, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, icsItem(e))
+	}
+	return items, nil
+}
+
+func icsItem(e ical.Entry) importItem {
+	it := importItem{ref: e.UID, title: e.Summary}
+	switch {
+	case e.Override:
+		// У изменённого повторения тот же UID, что и у основной записи,
+		// поэтому ссылка на неё не записывается.
+		it.ref = ""
+		it.note("changed occurrence of a recurring item is not supported")
+		return it
+	case e.Status == "CANCELLED":
+		it.note("cancelled item")
+		return it
+	case strings.TrimSpace(e.Summary) == "":
+		it.note("item has no summary")
+		return it
+	}
+
+	task := &db.Task{Title: e.Summary, Comment: e.Description, Status: db.StatusTodo}
+	date := e.Date
+	if date.IsZero() {
+		date = time.Now().UTC()
+	}
+	task.Date = date.Format(dateFormat)
+	switch e.Status {
+	case "COMPLETED":
+		task.Status = db.StatusDone
+	case "IN-PROCESS":
+		task.Status = db.StatusInProgress
+	}
+
+	if e.UID == "" {
+		it.note("item has no UID, re-import will duplicate it")
+	}
+	if len(e.Ignored) > 0 {
+		it.note("ignored %s", strings.Join(e.Ignored, ", "))
+	}
+	if e.RRule != "" {
+		repeat, lost, err := ical.Repeat(e.RRule, date)
+		switch {
+		case err != nil:
+			it.note("RRULE %s: %v, imported as a one-time task", e.RRule, err)
+		case len(lost) > 0:
+			it.note("RRULE %s: dropped %s, repeat rule %q", e.RRule, strings.Join(lost, ", "), repeat)
+		}
+		task.Repeat = repeat
+	}
+
+	it.task = checkForeign(task, &it)
+	return it
+}
+
+// checkForeign приводит дату задачи из внешнего формата к той, что
+// получила бы задача, добавленная вручную (см. checkDate). Если правило
+// повторения не подходит, задача переносится как разовая.
+func checkForeign(task *db.Task, it *importItem) *db.Task {
+	// Дата всегда в верном формате, поэтому ошибка возможна только в правиле повторения.
+	if err := checkDate(task); err != nil {
+		it.note("repeat rule %q: %v, imported as a one-time task", task.Repeat, err)
+		task.Repeat = ""
+		checkDate(task)
+	}
+	return task
+}
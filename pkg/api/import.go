@@ -0,0 +1,216 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// ImportReport — итог импорта из внешнего формата: сколько записей стало
+// задачами, сколько уже было загружено раньше и сколько пропущено.
+// При пробном запуске Tasks содержит задачи, которые были бы добавлены.
+type ImportReport struct {
+	DryRun     bool         `json:"dry_run,omitempty"`
+	Imported   int          `json:"imported"`
+	Duplicates int          `json:"duplicates"`
+	Skipped    int          `json:"skipped"`
+	Notes      []ImportNote `json:"notes,omitempty"`
+	Tasks      []*db.Task   `json:"tasks,omitempty"`
+}
+
+// ImportNote сообщает, что запись пропущена или перенесена не полностью.
+type ImportNote struct {
+	UID   string `json:"uid,omitempty"`
+	Title string `json:"title"`
+	Note  string `json:"note"`
+}
+
+// importItem — запись внешнего формата, переведённая в задачу.
+type importItem struct {
+	// ref — идентификатор записи во внешней системе, по которому повторный
+	// импорт её узнаёт; пустой, если у записи его нет.
+	ref   string
+	title string
+	// task равна nil, если запись перенести нельзя; причина — в notes.
+	task  *db.Task
+	notes []string
+}
+
+func (it *importItem) note(format string, args ...any) {
+	it.notes = append(it.notes, fmt.Sprintf(format, args...))
+}
+
+// importFormat читает файл внешнего формата. source — источник внешних
+// ссылок; пустой, если у записей формата нет постоянных идентификаторов.
+type importFormat struct {
+	source string
+	parse  func(r io.Reader) ([]importItem, error)
+}
+
+var importFormats = map[string]importFormat{
+	"ics":     {source: db.RefICal, parse: parseICS},
+	"todotxt": {parse: parseTodoTxt},
+	"todoist": {source: db.RefTodoist, parse: parseTodoist},
+	"trello":  {source: db.RefTrello, parse: parseTrello},
+}
+
+// errBadUpload отмечает ошибки в содержимом загруженного файла.
+var errBadUpload = errors.New("invalid file")
+
+// errDryRun откатывает транзакцию пробного импорта.
+var errDryRun = errors.New("dry run")
+
+// Import добавляет задачи из файла формата format (ics, todotxt, todoist,
+// trello) в одной транзакции. Записи, загруженные раньше, узнаются по
+// идентификатору во внешней системе и пропускаются. Что не удалось
+// перенести, перечисляется в отчёте. При dryRun изменения откатываются,
+// а отчёт показывает, что было бы сделано.
+func Import(store db.Store, format string, r io.Reader, dryRun bool) (*ImportReport, error) {
+	f, ok := importFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+	items, err := f.parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadUpload, err)
+	}
+
+	var report *ImportReport
+	err = store.InTx(func(tx db.Store) error {
+		report = &ImportReport{DryRun: dryRun}
+		if err := importItems(tx, f.source, items, report); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+func importItems(tx db.Store, source string, items []importItem, report *ImportReport) error {
+	seen := make(map[string]bool)
+	for _, it := range items {
+		title := it.title
+		if it.task != nil {
+			title = it.task.Title
+		}
+		notes := func() {
+			for _, n := range it.notes {
+				report.Notes = append(report.Notes, ImportNote{UID: it.ref, Title: title, Note: n})
+			}
+		}
+
+		if it.task == nil {
+			report.Skipped++
+			notes()
+			continue
+		}
+		if it.ref != "" && source != "" {
+			taskID, err := tx.ExternalRef(source, it.ref)
+			if err != nil {
+				return err
+			}
+			if taskID != "" || seen[it.ref] {
+				report.Duplicates++
+				continue
+			}
+		}
+
+		notes()
+		id, err := tx.AddTask(it.task)
+		if err != nil {
+			return err
+		}
+		report.Imported++
+		if report.DryRun {
+			task := *it.task
+			task.ID = ""
+			report.Tasks = append(report.Tasks, &task)
+		}
+
+		if it.ref == "" || source == "" {
+			continue
+		}
+		seen[it.ref] = true
+		ref := &db.ExternalRef{Source: source, Ref: it.ref, TaskID: strconv.FormatInt(id, 10)}
+		if err := tx.AddExternalRef(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportFileHandler возвращает обработчик загрузки файла формата format.
+// Файл передаётся телом запроса или полем file формы multipart/form-data;
+// параметр dry_run=1 только показывает отчёт, ничего не записывая.
+func (a *API) ImportFileHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+
+		dryRun, err := parseBool(r.URL.Query().Get("dry_run"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			writeError(w, err)
+			return
+		}
+
+		body, err := uploadedFile(w, r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			writeError(w, err)
+			return
+		}
+		defer body.Close()
+
+		report, err := Import(a.repo(r), format, body, dryRun)
+		if err != nil {
+			if errors.Is(err, errBadUpload) {
+				w.WriteHeader(http.StatusBadRequest)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, report)
+	}
+}
+
+// uploadedFile возвращает загруженный файл: поле file формы или тело запроса.
+func uploadedFile(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("error reading uploaded file: %v", err)
+	}
+	return file, nil
+}
+
+func parseBool(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean %q", s)
+	}
+	return b, nil
+}
@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"go1f/pkg/dates"
 )
 
 const dateFormat = "20060102"
@@ -254,7 +256,7 @@ func nextDayHandler(w http.ResponseWriter, r *http.Request) {
 	if nowStr == "" {
 		now = time.Now().UTC()
 	} else {
-		now, err = time.Parse(dateFormat, nowStr)
+		now, err = dates.Parse(nowStr, time.Now().UTC())
 		if err != nil {
 			http.Error(w, fmt.Sprintf("invalid date format for now: %v", err), http.StatusBadRequest)
 			return
@@ -266,6 +268,12 @@ func nextDayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Дату можно задать в любом формате dates.Parse, относительные слова
+	// отсчитываются от now. Неразобранную дату отвергнет NextDate.
+	if date, err := dates.Normalize(dateStr, now); err == nil {
+		dateStr = date
+	}
+
 	nextDate, err := NextDate(now, dateStr, repeat)
 	// Если ошибка связана с неоднозначностью – возвращаем пустой ответ.
 	if err != nil {
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"go1f/pkg/dates"
+	"go1f/pkg/db"
+)
+
+// maxRangeDays — наибольшая длина диапазона, в котором разворачиваются
+// повторы задач.
+const maxRangeDays = 366
+
+// dateRange — отбор задач по дате. Если expand, повторяющиеся задачи
+// показываются на каждый свой день в диапазоне, иначе — один раз на
+// сохранённую дату.
+type dateRange struct {
+	from, to string
+	expand   bool
+}
+
+// parseDateRange разбирает параметры списка задач:
+//   - due=overdue — просроченные задачи, с датой раньше сегодняшней;
+//   - due=today, due=week — сегодня и текущая неделя с понедельника;
+//   - days=N — N дней начиная с сегодняшнего;
+//   - from и to — явные границы в любом формате, который понимает dates.Parse.
+//
+// Повторы разворачиваются, только если заданы обе границы. Без параметров
+// возвращается nil.
+func parseDateRange(q url.Values, now time.Time) (*dateRange, error) {
+	due, days, from, to := q.Get("due"), q.Get("days"), q.Get("from"), q.Get("to")
+	set := 0
+	for _, param := range []string{due, days, from + to} {
+		if param != "" {
+			set++
+		}
+	}
+	if set == 0 {
+		return nil, nil
+	}
+	if set > 1 {
+		return nil, errors.New("due, days and from/to cannot be combined")
+	}
+
+	today := now.Format(dateFormat)
+	switch {
+	case due == "overdue":
+		return &dateRange{to: now.AddDate(0, 0, -1).Format(dateFormat)}, nil
+	case due == "today":
+		return &dateRange{from: today, to: today, expand: true}, nil
+	case due == "week":
+		monday := now.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+		return &dateRange{
+			from:   monday.Format(dateFormat),
+			to:     monday.AddDate(0, 0, 6).Format(dateFormat),
+			expand: true,
+		}, nil
+	case due != "":
+		return nil, fmt.Errorf("unknown due filter %q", due)
+	case days != "":
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 || n > maxRangeDays {
+			return nil, fmt.Errorf("days must be a number from 1 to %d", maxRangeDays)
+		}
+		return &dateRange{from: today, to: now.AddDate(0, 0, n-1).Format(dateFormat), expand: true}, nil
+	}
+
+	rng := &dateRange{}
+	var err error
+	if from != "" {
+		if rng.from, err = dates.Normalize(from, now); err != nil {
+			return nil, fmt.Errorf("invalid from date %q", from)
+		}
+	}
+	if to != "" {
+		if rng.to, err = dates.Normalize(to, now); err != nil {
+			return nil, fmt.Errorf("invalid to date %q", to)
+		}
+	}
+	if rng.from != "" && rng.to != "" {
+		if err := checkSpan(rng.from, rng.to); err != nil {
+			return nil, err
+		}
+		rng.expand = true
+	}
+	return rng, nil
+}
+
+// checkSpan проверяет, что диапазон from..to не пуст и не длиннее maxRangeDays.
+func checkSpan(from, to string) error {
+	start, _ := time.Parse(dateFormat, from)
+	end, _ := time.Parse(dateFormat, to)
+	if end.Before(start) {
+		return errors.New("from must not be after to")
+	}
+	if end.Sub(start) >= maxRangeDays*24*time.Hour {
+		return fmt.Errorf("date range is longer than %d days", maxRangeDays)
+	}
+	return nil
+}
+
+// occurrences возвращает даты задачи в диапазоне from..to включительно.
+// Повторяющаяся задача с датой раньше from начинает с первого повтора в
+// диапазоне.
+func occurrences(task *db.Task, from, to string) []string {
+	next := task.Date
+	if task.Repeat != "" && next < from {
+		start, _ := time.Parse(dateFormat, from)
+		var err error
+		if next, err = NextDate(start.AddDate(0, 0, -1), repeatBase(task, next), task.Repeat); err != nil {
+			return nil
+		}
+	}
+
+	var dates []string
+	for next >= from && next <= to {
+		dates = append(dates, next)
+		if task.Repeat == "" {
+			break
+		}
+		day, _ := time.Parse(dateFormat, next)
+		var err error
+		if next, err = NextDate(day, repeatBase(task, next), task.Repeat); err != nil {
+			break
+		}
+	}
+	return dates
+}
+
+// repeatBase возвращает дату, от которой считается повтор после date. После
+// отложенной даты повторы идут по прежнему расписанию, от Anchor.
+func repeatBase(task *db.Task, date string) string {
+	if task.Anchor != "" && date == task.Date {
+		return task.Anchor
+	}
+	return date
+}
+
+// expandTasks заменяет каждую повторяющуюся задачу её повторами в диапазоне
+// и упорядочивает результат по дате и идентификатору.
+func expandTasks(tasks []*db.Task, rng *dateRange) []*db.Task {
+	var expanded []*db.Task
+	for _, task := range tasks {
+		for _, date := range occurrences(task, rng.from, rng.to) {
+			occ := *task
+			occ.Date = date
+			occ.Virtual = date != task.Date
+			expanded = append(expanded, &occ)
+		}
+	}
+	sortTasks(expanded)
+	return expanded
+}
+
+func sortTasks(tasks []*db.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Date != tasks[j].Date {
+			return tasks[i].Date < tasks[j].Date
+		}
+		return taskID(tasks[i]) < taskID(tasks[j])
+	})
+}
+
+// pageAfter отбрасывает задачи до курсора включительно; задачи должны
+// быть упорядочены sortTasks.
+func pageAfter(tasks []*db.Task, after *db.Cursor) []*db.Task {
+	if after == nil {
+		return tasks
+	}
+	i := sort.Search(len(tasks), func(i int) bool {
+		return tasks[i].Date > after.Date || tasks[i].Date == after.Date && taskID(tasks[i]) > after.ID
+	})
+	return tasks[i:]
+}
+
+func taskID(task *db.Task) int64 {
+	id, _ := strconv.ParseInt(task.ID, 10, 64)
+	return id
+}
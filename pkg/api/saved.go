@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go1f/pkg/db"
+)
+
+type SavedSearchesResp struct {
+	Searches []*db.SavedSearch `json:"searches"`
+}
+
+// SavedSearchHandler читает, создаёт, изменяет и удаляет сохранённые поиски.
+// Задачи сохранённого поиска отдаёт GET /api/tasks?saved=<id>.
+func (a *API) SavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.getSavedSearchHandler(w, r)
+	case http.MethodPost:
+		a.addSavedSearchHandler(w, r)
+	case http.MethodPut:
+		a.updateSavedSearchHandler(w, r)
+	case http.MethodDelete:
+		a.deleteSavedSearchHandler(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) SavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	searches, err := a.repo(r).SavedSearches()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, SavedSearchesResp{Searches: searches})
+}
+
+func (a *API) getSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "id is required"})
+		return
+	}
+
+	search, err := a.repo(r).GetSavedSearch(id)
+	if err != nil {
+		writeSavedSearchError(w, err)
+		return
+	}
+
+	writeJSON(w, search)
+}
+
+func (a *API) addSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var search db.SavedSearch
+	if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error reading JSON: %v", err)})
+		return
+	}
+
+	if err := checkSavedSearch(&search); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	id, err := a.repo(r).AddSavedSearch(&search)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error adding saved search: %v", err)})
+		return
+	}
+
+	writeJSON(w, map[string]string{"id": strconv.FormatInt(id, 10)})
+}
+
+func (a *API) updateSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var search db.SavedSearch
+	if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error reading JSON: %v", err)})
+		return
+	}
+
+	if search.ID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+	if err := checkSavedSearch(&search); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := a.repo(r).UpdateSavedSearch(&search); err != nil {
+		writeSavedSearchError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{})
+}
+
+func (a *API) deleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	if err := a.repo(r).DeleteSavedSearch(id); err != nil {
+		writeSavedSearchError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{})
+}
+
+func writeSavedSearchError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrSavedSearchNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	writeJSON(w, map[string]string{"error": err.Error()})
+}
+
+// checkSavedSearch проверяет название, строку поиска и порядок задач.
+func checkSavedSearch(search *db.SavedSearch) error {
+	search.Name = strings.TrimSpace(search.Name)
+	if search.Name == "" {
+		return errors.New("saved search name is required")
+	}
+	if _, err := db.ParseQuery(search.Query); err != nil {
+		return err
+	}
+	if !db.ValidSort(search.Sort) {
+		return fmt.Errorf("unknown sort %q", search.Sort)
+	}
+	return nil
+}
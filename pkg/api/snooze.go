@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go1f/pkg/dates"
+	"go1f/pkg/db"
+	"net/http"
+	"time"
+)
+
+type SnoozeReq struct {
+	ID   string `json:"id"`
+	By   string `json:"by"`
+	Date string `json:"date"`
+}
+
+type SnoozeResp struct {
+	Date string `json:"date"`
+}
+
+// SnoozeTaskHandler откладывает задачу: меняет только её дату,
+// правило повторения и остальные поля остаются прежними. Расписание
+// повторов тоже не сдвигается: после выполнения отложенная задача
+// переносится на следующий повтор от прежней даты (см. db.Task.Anchor).
+// Новая дата задаётся либо относительно (by: "+1d", "+1w", "next monday",
+// «в пятницу»), либо явно (date: YYYYMMDD, DD.MM.YYYY, ISO 8601 или «завтра»).
+func (a *API) SnoozeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SnoozeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid data format"})
+		return
+	}
+
+	if req.ID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	if (req.By == "") == (req.Date == "") {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "either by or date is required"})
+		return
+	}
+
+	var next string
+	err := a.repo(r).InTx(func(tx db.Store) error {
+		task, err := tx.GetTask(req.ID)
+		if err != nil {
+			return err
+		}
+		if next, err = snoozeDate(task, req, time.Now().UTC()); err != nil {
+			return err
+		}
+		return tx.UpdateDate(next, task.ID)
+	})
+	if errors.Is(err, db.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, SnoozeResp{Date: next})
+}
+
+// snoozeDate вычисляет новую дату задачи. Относительный сдвиг "+N"
+// отсчитывается от даты задачи, а если она уже прошла — от сегодняшнего дня.
+func snoozeDate(task *db.Task, req SnoozeReq, now time.Time) (string, error) {
+	today, _ := time.Parse(dateFormat, now.Format(dateFormat))
+
+	var next time.Time
+	if req.Date != "" {
+		t, err := dates.Parse(req.Date, now)
+		if err != nil {
+			return "", fmt.Errorf("invalid date format")
+		}
+		next = t
+	} else {
+		base, err := time.Parse(dateFormat, task.Date)
+		if err != nil || base.Before(today) {
+			base = today
+		}
+		next, err = dates.ParseFrom(req.By, base, now)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if next.Before(today) {
+		return "", fmt.Errorf("date is in the past")
+	}
+	return next.Format(dateFormat), nil
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go1f/pkg/dates"
+	"go1f/pkg/db"
+)
+
+// statsDays — длина периода статистики, если from не задан.
+const statsDays = 30
+
+// historyStart — дата раньше любой отметки о выполнении. Отметки читаются
+// с неё, чтобы серия, начавшаяся до периода, считалась целиком.
+const historyStart = "00010101"
+
+// DayStats — выполненные за день задачи; Late — сколько из них выполнено
+// позже своей даты.
+type DayStats struct {
+	Date string `json:"date"`
+	Done int    `json:"done"`
+	Late int    `json:"late"`
+}
+
+// WeekStats — то же за неделю, начинающуюся в понедельник Week.
+type WeekStats struct {
+	Week string `json:"week"`
+	Done int    `json:"done"`
+	Late int    `json:"late"`
+}
+
+// Streak — серии повторяющейся задачи: сколько раз подряд она выполнялась
+// в срок. Серии считаются по всей истории задачи до конца периода.
+// Current — серия, которая продолжается сейчас; просроченная задача
+// её обнуляет.
+type Streak struct {
+	TaskID  string `json:"id"`
+	Title   string `json:"title"`
+	Current int    `json:"current"`
+	Longest int    `json:"longest"`
+}
+
+type StatsResp struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Done int    `json:"done"`
+	// Overdue — активные задачи с датой раньше сегодняшней.
+	Overdue int `json:"overdue"`
+	// AvgLateness — среднее опоздание выполнения в днях; выполненные
+	// раньше срока считаются выполненными вовремя.
+	AvgLateness float64      `json:"avg_lateness"`
+	Days        []*DayStats  `json:"days"`
+	Weeks       []*WeekStats `json:"weeks"`
+	Streaks     []*Streak    `json:"streaks"`
+}
+
+// statsCache хранит посчитанную статистику, пока в хранилище нет новых
+// ревизий и не сменился день.
+type statsCache struct {
+	mu      sync.Mutex
+	rev     int64
+	today   string
+	entries map[[2]string]*StatsResp
+}
+
+// statsCacheSize ограничивает число разных периодов в кэше.
+const statsCacheSize = 64
+
+func (c *statsCache) get(rev int64, today, from, to string) *StatsResp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rev != rev || c.today != today {
+		return nil
+	}
+	return c.entries[[2]string{from, to}]
+}
+
+func (c *statsCache) put(rev int64, today, from, to string, stats *StatsResp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rev != rev || c.today != today || len(c.entries) >= statsCacheSize {
+		c.rev, c.today = rev, today
+		c.entries = make(map[[2]string]*StatsResp)
+	}
+	c.entries[[2]string{from, to}] = stats
+}
+
+// StatsHandler отдаёт статистику выполнения задач за период from..to
+// (по умолчанию — последние statsDays дней). Она считается по отметкам о
+// выполнении из истории задач и кэшируется до следующего изменения задач.
+func (a *API) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now().UTC()
+	from, to, err := parseStatsRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"), now)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+
+	store := a.repo(r)
+	rev, err := store.LastRevisionID()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	today := now.Format(dateFormat)
+	if stats := a.stats.get(rev, today, from, to); stats != nil {
+		writeJSON(w, stats)
+		return
+	}
+
+	completions, err := store.Completions(historyStart, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	overdue, err := store.Tasks(math.MaxInt, db.TaskFilter{
+		Statuses: db.ActiveStatuses,
+		To:       now.AddDate(0, 0, -1).Format(dateFormat),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	stats := computeStats(completions, overdue, from, to)
+	a.stats.put(rev, today, from, to, stats)
+	writeJSON(w, stats)
+}
+
+// parseStatsRange разбирает границы периода в любом формате, который
+// понимает dates.Parse. Без to период заканчивается сегодня, без from начинается за
+// statsDays-1 дней до to.
+func parseStatsRange(from, to string, now time.Time) (string, string, error) {
+	end := now.Format(dateFormat)
+	var err error
+	if to != "" {
+		if end, err = dates.Normalize(to, now); err != nil {
+			return "", "", fmt.Errorf("invalid to date %q", to)
+		}
+	}
+	var start string
+	if from != "" {
+		if start, err = dates.Normalize(from, now); err != nil {
+			return "", "", fmt.Errorf("invalid from date %q", from)
+		}
+	} else {
+		last, _ := time.Parse(dateFormat, end)
+		start = last.AddDate(0, 0, 1-statsDays).Format(dateFormat)
+	}
+	if err := checkSpan(start, end); err != nil {
+		return "", "", err
+	}
+	return start, end, nil
+}
+
+// computeStats считает статистику по отметкам о выполнении, упорядоченным
+// от старых к новым, и просроченным задачам. Отметки до from учитываются
+// только в сериях задач, выполненных за период.
+func computeStats(completions []*db.Revision, overdue []*db.Task, from, to string) *StatsResp {
+	stats := &StatsResp{
+		From:    from,
+		To:      to,
+		Overdue: len(overdue),
+		Days:    make([]*DayStats, 0),
+		Weeks:   make([]*WeekStats, 0),
+		Streaks: make([]*Streak, 0),
+	}
+
+	start, _ := time.Parse(dateFormat, from)
+	days := make(map[string]*DayStats)
+	weeks := make(map[string]*WeekStats)
+	for day := start; day.Format(dateFormat) <= to; day = day.AddDate(0, 0, 1) {
+		d := &DayStats{Date: day.Format(dateFormat)}
+		stats.Days = append(stats.Days, d)
+		days[d.Date] = d
+
+		monday := day.AddDate(0, 0, -(int(day.Weekday())+6)%7).Format(dateFormat)
+		if weeks[monday] == nil {
+			weeks[monday] = &WeekStats{Week: monday}
+			stats.Weeks = append(stats.Weeks, weeks[monday])
+		}
+	}
+
+	isOverdue := make(map[string]bool, len(overdue))
+	for _, task := range overdue {
+		isOverdue[task.ID] = true
+	}
+
+	streaks := make(map[string]*Streak)
+	inRange := make(map[string]bool)
+	lateness := 0
+	for _, rev := range completions {
+		if rev.Before == nil {
+			continue
+		}
+		doneAt, err := time.Parse(time.RFC3339, rev.CreatedAt)
+		if err != nil {
+			continue
+		}
+		doneAt = doneAt.UTC()
+		if doneAt.Format(dateFormat) > to {
+			continue
+		}
+
+		late := 0
+		if due, err := time.Parse(dateFormat, rev.Before.Date); err == nil {
+			late = max(0, int(doneAt.Truncate(24*time.Hour).Sub(due).Hours()/24))
+		}
+		if day := days[doneAt.Format(dateFormat)]; day != nil {
+			week := weeks[doneAt.AddDate(0, 0, -(int(doneAt.Weekday())+6)%7).Format(dateFormat)]
+			stats.Done++
+			day.Done++
+			week.Done++
+			if late > 0 {
+				day.Late++
+				week.Late++
+			}
+			lateness += late
+			inRange[rev.TaskID] = true
+		}
+
+		if rev.Before.Repeat == "" {
+			continue
+		}
+		streak := streaks[rev.TaskID]
+		if streak == nil {
+			streak = &Streak{TaskID: rev.TaskID}
+			streaks[rev.TaskID] = streak
+		}
+		streak.Title = rev.Before.Title
+		if late > 0 {
+			streak.Current = 0
+		} else {
+			streak.Current++
+			streak.Longest = max(streak.Longest, streak.Current)
+		}
+	}
+
+	for id, streak := range streaks {
+		if !inRange[id] {
+			continue
+		}
+		if isOverdue[id] {
+			streak.Current = 0
+		}
+		stats.Streaks = append(stats.Streaks, streak)
+	}
+	sort.Slice(stats.Streaks, func(i, j int) bool {
+		a, _ := strconv.ParseInt(stats.Streaks[i].TaskID, 10, 64)
+		b, _ := strconv.ParseInt(stats.Streaks[j].TaskID, 10, 64)
+		return a < b
+	})
+	if stats.Done > 0 {
+		stats.AvgLateness = math.Round(float64(lateness)/float64(stats.Done)*100) / 100
+	}
+	return stats
+}
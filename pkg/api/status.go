@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"go1f/pkg/db"
+	"net/http"
+)
+
+type StatusReq struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Version int64  `json:"version,string"`
+}
+
+// StatusTaskHandler меняет статус задачи с учётом допустимых переходов.
+// Переход в "done" работает так же, как /api/task/done, и так же требует
+// версию задачи; для остальных статусов она проверяется, если передана.
+func (a *API) StatusTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req StatusReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid data format"})
+		return
+	}
+
+	if req.ID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	if !db.ValidStatus(req.Status) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "unknown task status"})
+		return
+	}
+
+	version, err := requestVersion(r, req.Version)
+	if errors.Is(err, errPreconditionRequired) && req.Status != db.StatusDone {
+		err = nil
+	}
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+
+	err = changeStatus(a.repo(r), req.ID, req.Status, version)
+	var transition *db.TransitionError
+	switch {
+	case errors.Is(err, db.ErrConflict):
+		a.writeConflict(w, req.ID)
+		return
+	case errors.Is(err, db.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	case errors.As(err, &transition):
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{})
+}
+
+// changeStatus переводит задачу в новый статус; переход в "done"
+// выполняется так же, как в /api/task/done. Ненулевая version должна
+// совпадать с текущей версией задачи.
+func changeStatus(repo db.Store, id string, status string, version int64) error {
+	if status == db.StatusDone {
+		return repo.CompleteTask(id, version, nextTaskDate)
+	}
+	return repo.InTx(func(tx db.Store) error {
+		task, err := tx.GetTask(id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != task.Version {
+			return db.ErrConflict
+		}
+		return tx.UpdateStatus(id, status)
+	})
+}
@@ -2,26 +2,27 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"go1f/pkg/db"
 	"net/http"
 )
 
-func TaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) TaskHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		getTaskHandler(w, r)
+		a.getTaskHandler(w, r)
 	case http.MethodPut:
-		updateTaskHandler(w, r)
+		a.updateTaskHandler(w, r)
 	case http.MethodPost:
-		addTaskHandler(w, r)
+		a.addTaskHandler(w, r)
 	case http.MethodDelete:
-		deleteTaskHandler(w, r)
+		a.deleteTaskHandler(w, r)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func getTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -29,16 +30,17 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := db.GetTask(id)
+	task, err := a.repo(r).GetTask(id)
 	if err != nil {
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
 
+	setETag(w, task)
 	writeJSON(w, task)
 }
 
-func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var task db.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -62,22 +64,46 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.UpdateTask(&task); err != nil {
+	version, err := requestVersion(r, task.Version)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+	task.Version = version
+
+	err = a.repo(r).UpdateTask(&task)
+	if errors.Is(err, db.ErrConflict) {
+		a.writeConflict(w, task.ID)
+		return
+	}
+	if err != nil {
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
 
+	setETag(w, &task)
 	writeJSON(w, map[string]interface{}{})
 }
 
-func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
 		return
 	}
 
-	if err := db.DeleteTask(id); err != nil {
+	version, err := requestVersion(r, 0)
+	if err != nil {
+		writeVersionError(w, err)
+		return
+	}
+
+	err = a.repo(r).DeleteTask(id, version)
+	if errors.Is(err, db.ErrConflict) {
+		a.writeConflict(w, id)
+		return
+	}
+	if err != nil {
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
@@ -1,14 +1,29 @@
 package api
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"go1f/pkg/db"
 )
 
+// Размер страницы списка задач: по умолчанию и наибольший допустимый.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
 type TasksResp struct {
 	Tasks []*db.Task `json:"tasks"`
+	// NextCursor передаётся в параметре cursor, чтобы получить следующую
+	// страницу; пустой, если задач больше нет.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type ErrorResp struct {
@@ -24,7 +39,7 @@ func writeError(w http.ResponseWriter, err error) {
 	writeJSON(w, ErrorResp{Error: err.Error()})
 }
 
-func tasksHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) tasksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
@@ -32,13 +47,157 @@ func tasksHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Получаем параметр поиска
 	search := r.URL.Query().Get("search")
+	sort := r.URL.Query().Get("sort")
+	if id := r.URL.Query().Get("saved"); id != "" {
+		// Строка search уточняет сохранённый поиск, а sort заменяет его порядок.
+		saved, err := a.repo(r).GetSavedSearch(id)
+		if err != nil {
+			writeSavedSearchError(w, err)
+			return
+		}
+		search = strings.TrimSpace(saved.Query + " " + search)
+		if sort == "" {
+			sort = saved.Sort
+		}
+	}
+	if _, err := db.ParseQuery(search); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	if !db.ValidSort(sort) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, fmt.Errorf("unknown sort %q", sort))
+		return
+	}
 
-	// Получаем задачи с разумным ограничением
-	tasks, err := db.Tasks(50, search)
+	statuses, err := parseStatuses(r.URL.Query().Get("status"))
 	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
 		writeError(w, err)
 		return
 	}
 
-	writeJSON(w, TasksResp{Tasks: tasks})
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	after, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+
+	rng, err := parseDateRange(r.URL.Query(), time.Now().UTC())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+
+	filter := db.TaskFilter{Search: search, Statuses: statuses, Sort: sort, After: after}
+	if rng != nil {
+		filter.From, filter.To, filter.Repeating = rng.from, rng.to, rng.expand
+	}
+	if rng != nil && rng.expand {
+		a.expandedTasks(w, r, filter, rng, limit)
+		return
+	}
+
+	// Лишняя задача показывает, есть ли следующая страница
+	tasks, err := a.repo(r).Tasks(limit+1, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := TasksResp{Tasks: tasks}
+	if len(tasks) > limit {
+		resp.Tasks = tasks[:limit]
+		resp.NextCursor = encodeCursor(filter.NextCursor(resp.Tasks))
+	}
+	writeJSON(w, resp)
+}
+
+// expandedTasks отдаёт страницу задач диапазона, в которой повторяющиеся
+// задачи развёрнуты по дням. Повторы вычисляются по всем задачам диапазона,
+// а курсор отсчитывается от повторов, поэтому выдача всегда идёт по дате.
+func (a *API) expandedTasks(w http.ResponseWriter, r *http.Request, filter db.TaskFilter, rng *dateRange, limit int) {
+	after := filter.After
+	filter.After = nil
+	tasks, err := a.repo(r).Tasks(math.MaxInt, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tasks = pageAfter(expandTasks(tasks, rng), after)
+	resp := TasksResp{Tasks: tasks}
+	if len(tasks) > limit {
+		resp.Tasks = tasks[:limit]
+		last := resp.Tasks[limit-1]
+		resp.NextCursor = encodeCursor(&db.Cursor{Date: last.Date, ID: taskID(last)})
+	}
+	if resp.Tasks == nil {
+		resp.Tasks = make([]*db.Task, 0)
+	}
+	writeJSON(w, resp)
+}
+
+// parseLimit разбирает параметр limit: размер страницы от 1 до maxPageSize.
+// Больший размер урезается до maxPageSize.
+func parseLimit(param string) (int, error) {
+	if param == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(param)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid limit %q", param)
+	}
+	return min(limit, maxPageSize), nil
+}
+
+// encodeCursor упаковывает курсор в непрозрачную строку для клиента.
+func encodeCursor(c *db.Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(param string) (*db.Cursor, error) {
+	if param == "" {
+		return nil, nil
+	}
+	var c db.Cursor
+	data, err := base64.RawURLEncoding.DecodeString(param)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.Offset < 0 {
+		return nil, fmt.Errorf("invalid cursor %q", param)
+	}
+	return &c, nil
+}
+
+// parseStatuses разбирает параметр status: список статусов через запятую.
+// Без параметра возвращаются незавершённые задачи, значение "all" снимает фильтр.
+func parseStatuses(param string) ([]string, error) {
+	switch param {
+	case "":
+		return db.ActiveStatuses, nil
+	case "all":
+		return nil, nil
+	}
+
+	var statuses []string
+	for _, s := range strings.Split(param, ",") {
+		s = strings.TrimSpace(s)
+		if !db.ValidStatus(s) {
+			return nil, fmt.Errorf("unknown status %q", s)
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
 }
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"go1f/pkg/db"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type TemplatesResp struct {
+	Templates []*db.Template `json:"templates"`
+}
+
+type ApplyTemplateReq struct {
+	ID   string `json:"id"`
+	Date string `json:"date"`
+}
+
+type ApplyTemplateResp struct {
+	IDs []string `json:"ids"`
+}
+
+func (a *API) TemplateHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.getTemplateHandler(w, r)
+	case http.MethodPost:
+		a.addTemplateHandler(w, r)
+	case http.MethodDelete:
+		a.deleteTemplateHandler(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templates, err := a.repo(r).Templates()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, TemplatesResp{Templates: templates})
+}
+
+func (a *API) getTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "id is required"})
+		return
+	}
+
+	tpl, err := a.repo(r).GetTemplate(id)
+	if err != nil {
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, tpl)
+}
+
+func (a *API) addTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var tpl db.Template
+	if err := json.NewDecoder(r.Body).Decode(&tpl); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error reading JSON: %v", err)})
+		return
+	}
+
+	if err := checkTemplate(&tpl); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	id, err := a.repo(r).AddTemplate(&tpl)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error adding template: %v", err)})
+		return
+	}
+
+	writeJSON(w, map[string]string{"id": fmt.Sprintf("%d", id)})
+}
+
+func (a *API) deleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	if err := a.repo(r).DeleteTemplate(id); err != nil {
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{})
+}
+
+// ApplyTemplateHandler создаёт задачи шаблона начиная с указанной даты.
+// Все задачи добавляются в одной транзакции: либо все, либо ни одной.
+func (a *API) ApplyTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ApplyTemplateReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid data format"})
+		return
+	}
+
+	if req.ID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Не указан идентификатор"})
+		return
+	}
+
+	tpl, err := a.repo(r).GetTemplate(req.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	tasks, err := templateToTasks(tpl, req.Date)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ids, err := a.repo(r).AddTasks(tasks)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("error adding tasks: %v", err)})
+		return
+	}
+
+	resp := ApplyTemplateResp{IDs: make([]string, 0, len(ids))}
+	for _, id := range ids {
+		resp.IDs = append(resp.IDs, strconv.FormatInt(id, 10))
+	}
+	writeJSON(w, resp)
+}
+
+func checkTemplate(tpl *db.Template) error {
+	if tpl.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if len(tpl.Tasks) == 0 {
+		return fmt.Errorf("template has no tasks")
+	}
+
+	today := time.Now().UTC().Format(dateFormat)
+	for i, t := range tpl.Tasks {
+		if t.Title == "" {
+			return fmt.Errorf("task %d: task title is required", i+1)
+		}
+		if t.Offset < 0 {
+			return fmt.Errorf("task %d: offset must not be negative", i+1)
+		}
+		if t.Repeat != "" {
+			if _, err := NextDate(time.Now().UTC(), today, t.Repeat); err != nil {
+				return fmt.Errorf("task %d: invalid repeat rule", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// templateToTasks строит задачи шаблона для даты start (YYYYMMDD, по умолчанию — сегодня).
+func templateToTasks(tpl *db.Template, start string) ([]*db.Task, error) {
+	if start == "" {
+		start = time.Now().UTC().Format(dateFormat)
+	}
+	startDate, err := time.Parse(dateFormat, start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format")
+	}
+
+	tasks := make([]*db.Task, 0, len(tpl.Tasks))
+	for _, t := range tpl.Tasks {
+		task := &db.Task{
+			Date:    startDate.AddDate(0, 0, t.Offset).Format(dateFormat),
+			Title:   t.Title,
+			Comment: t.Comment,
+			Repeat:  t.Repeat,
+		}
+		if err := checkDate(task); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.Title, err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
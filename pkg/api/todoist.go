@@ -0,0 +1,98 @@
+package api
+
+import (
+	"go1f/pkg/db"
+	"go1f/pkg/todoist"
+	"io"
+	"strings"
+	"time"
+)
+
+// parseTodoist читает выгрузку Todoist. Проект и метки дописываются
+// к названию как +проект и @метка, подзадачи становятся чек-листом
+// в комментарии, строка повторения — ближайшим правилом повторения.
+func parseTodoist(r io.Reader) ([]importItem, error) {
+	items, err := todoist.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]importItem, 0, len(items))
+	for _, item := range items {
+		it := importItem{ref: item.ID, title: item.Content}
+		if strings.TrimSpace(item.Content) == "" {
+			it.note("item has no content")
+			result = append(result, it)
+			continue
+		}
+
+		task := &db.Task{
+			Title:  tagged(item.Content, item.Project, item.Labels),
+			Status: db.StatusTodo,
+		}
+		if item.Completed {
+			task.Status = db.StatusDone
+		}
+
+		var checklist strings.Builder
+		writeSubtasks(&checklist, item.Children, "")
+		task.Comment = joinParagraphs(item.Description, checklist.String())
+
+		date := item.Date
+		if date.IsZero() {
+			date = time.Now().UTC()
+		}
+		task.Date = date.Format(dateFormat)
+		if item.Recurrence != "" {
+			repeat, lost, err := todoist.Repeat(item.Recurrence, date)
+			switch {
+			case err != nil:
+				it.note("%v, imported as a one-time task", err)
+			case len(lost) > 0:
+				it.note("recurrence %q: dropped %s, repeat rule %q", item.Recurrence, strings.Join(lost, ", "), repeat)
+			}
+			task.Repeat = repeat
+		}
+
+		it.task = checkForeign(task, &it)
+		result = append(result, it)
+	}
+	return result, nil
+}
+
+func writeSubtasks(b *strings.Builder, items []*todoist.Item, indent string) {
+	for _, item := range items {
+		b.WriteString(indent + checkbox(item.Completed) + item.Content + "\n")
+		writeSubtasks(b, item.Children, indent+"  ")
+	}
+}
+
+// tagged дописывает к названию проект и метки в записи todo.txt.
+func tagged(title, project string, labels []string) string {
+	words := []string{strings.TrimSpace(title)}
+	if project != "" {
+		words = append(words, "+"+strings.Join(strings.Fields(project), "_"))
+	}
+	for _, l := range labels {
+		words = append(words, "@"+strings.Join(strings.Fields(l), "_"))
+	}
+	return strings.Join(words, " ")
+}
+
+func checkbox(done bool) string {
+	if done {
+		return "- [x] "
+	}
+	return "- [ ] "
+}
+
+// joinParagraphs склеивает непустые части комментария через пустую строку.
+func joinParagraphs(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"go1f/pkg/db"
+	"go1f/pkg/todotxt"
+	"io"
+	"strings"
+)
+
+// ExportTodoTxt пишет все задачи строками todo.txt.
+func ExportTodoTxt(w io.Writer, store db.Store) error {
+	bw := bufio.NewWriter(w)
+	err := store.EachTask(func(task *db.Task) error {
+		_, err := bw.WriteString(todotxt.Format(task) + "\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// parseTodoTxt читает строки todo.txt. Даты сохраняются такими, как
+// записаны в файле; строки с ошибками пропускаются.
+func parseTodoTxt(r io.Reader) ([]importItem, error) {
+	var items []importItem
+	sc := bufio.NewScanner(r)
+	for num := 1; sc.Scan(); num++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		it := importItem{title: line}
+		task, lost, err := todotxt.Parse(line)
+		if err == nil {
+			err = checkImported(task)
+		}
+		if err != nil {
+			it.note("line %d: %v", num, err)
+		} else {
+			it.task = task
+			if len(lost) > 0 {
+				it.note("line %d: dropped %s", num, strings.Join(lost, ", "))
+			}
+		}
+		items = append(items, it)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading todo.txt: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"go1f/pkg/db"
+	"go1f/pkg/trello"
+	"io"
+	"strings"
+)
+
+// parseTrello читает выгрузку доски Trello. Колонка и метки дописываются
+// к названию как +колонка и @метка, чек-листы — к комментарию. Карточки
+// из архива пропускаются.
+func parseTrello(r io.Reader) ([]importItem, error) {
+	cards, err := trello.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]importItem, 0, len(cards))
+	for _, card := range cards {
+		it := importItem{ref: card.ID, title: card.Name}
+		switch {
+		case card.Archived:
+			it.note("archived card")
+			result = append(result, it)
+			continue
+		case strings.TrimSpace(card.Name) == "":
+			it.note("card has no name")
+			result = append(result, it)
+			continue
+		}
+
+		task := &db.Task{
+			Title:  tagged(card.Name, card.List, card.Labels),
+			Status: db.StatusTodo,
+		}
+		if card.Done {
+			task.Status = db.StatusDone
+		}
+		if !card.Date.IsZero() {
+			task.Date = card.Date.Format(dateFormat)
+		}
+
+		var checklists []string
+		for _, cl := range card.Checklists {
+			var b strings.Builder
+			b.WriteString(cl.Name + ":\n")
+			for _, item := range cl.Items {
+				b.WriteString(checkbox(item.Done) + item.Name + "\n")
+			}
+			checklists = append(checklists, b.String())
+		}
+		task.Comment = joinParagraphs(append([]string{card.Desc}, checklists...)...)
+
+		it.task = checkForeign(task, &it)
+		result = append(result, it)
+	}
+	return result, nil
+}
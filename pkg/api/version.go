@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	errPreconditionRequired = errors.New("task version is required: send If-Match or version")
+	errInvalidVersion       = errors.New("invalid task version")
+)
+
+// versionRequired сообщает, обязан ли клиент передавать версию задачи при
+// изменении, удалении и завершении. Так по умолчанию: иначе две вкладки
+// молча перезаписывают изменения друг друга. TODO_UNVERSIONED_WRITES
+// отключает требование для старых клиентов — тогда версия проверяется,
+// только если передана.
+func versionRequired() bool {
+	return os.Getenv("TODO_UNVERSIONED_WRITES") == ""
+}
+
+// requestVersion возвращает версию задачи, которую ожидает клиент: из заголовка
+// If-Match, а если его нет — из параметра version или из тела запроса (fallback).
+// Ноль означает, что версия не проверяется: так бывает при If-Match: * или,
+// если versionRequired выключен, без версии вовсе.
+func requestVersion(r *http.Request, fallback int64) (int64, error) {
+	if match := strings.TrimSpace(r.Header.Get("If-Match")); match != "" {
+		if match == "*" {
+			return 0, nil
+		}
+		match = strings.Trim(strings.TrimPrefix(match, "W/"), `"`)
+		version, err := strconv.ParseInt(match, 10, 64)
+		if err != nil || version <= 0 {
+			return 0, errInvalidVersion
+		}
+		return version, nil
+	}
+
+	if param := r.URL.Query().Get("version"); param != "" {
+		version, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || version <= 0 {
+			return 0, errInvalidVersion
+		}
+		return version, nil
+	}
+
+	if fallback == 0 && versionRequired() {
+		return 0, errPreconditionRequired
+	}
+	return fallback, nil
+}
+
+// writeVersionError отвечает на ошибку разбора версии из requestVersion.
+func writeVersionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errPreconditionRequired) {
+		w.WriteHeader(http.StatusPreconditionRequired)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	writeError(w, err)
+}
+
+func setETag(w http.ResponseWriter, task *db.Task) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, task.Version))
+}
+
+type ConflictResp struct {
+	Error string   `json:"error"`
+	Task  *db.Task `json:"task,omitempty"`
+}
+
+// writeConflict отвечает 412 и текущим состоянием задачи, чтобы клиент мог
+// показать изменения и повторить запрос с новой версией.
+func (a *API) writeConflict(w http.ResponseWriter, id string) {
+	resp := ConflictResp{Error: db.ErrConflict.Error()}
+	if task, err := a.store.GetTask(id); err == nil {
+		resp.Task = task
+		setETag(w, task)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(resp)
+}
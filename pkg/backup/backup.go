@@ -0,0 +1,190 @@
+// Package backup создаёт снимки базы по расписанию, удаляет старые
+// и восстанавливает базу из снимка.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	prefix     = "scheduler-"
+	suffix     = ".db"
+	timeFormat = "20060102-150405"
+)
+
+// Snapshot сохраняет снимок базы в каталог dir и возвращает путь к нему.
+func Snapshot(b db.Backuper, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, prefix+now.UTC().Format(timeFormat)+suffix)
+	if err := b.Backup(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Snapshots возвращает снимки из каталога dir от старых к новым.
+func Snapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		if _, err := time.Parse(timeFormat, stamp); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	// Имена содержат время снимка, поэтому сортируются по времени.
+	sort.Strings(files)
+	return files, nil
+}
+
+// Rotate оставляет в каталоге dir только keep последних снимков.
+// При keep <= 0 снимки не удаляются.
+func Rotate(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	files, err := Snapshots(dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > keep {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Schedule каждые interval сохраняет снимок в dir и оставляет keep
+// последних, пока не отменён ctx. Ошибки записываются в лог.
+func Schedule(ctx context.Context, b db.Backuper, dir string, interval time.Duration, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			path, err := Snapshot(b, dir, now)
+			if err != nil {
+				log.Printf("backup failed: %v", err)
+				continue
+			}
+			log.Printf("backup saved to %s", path)
+			if err := Rotate(dir, keep); err != nil {
+				log.Printf("backup rotation failed: %v", err)
+			}
+		}
+	}
+}
+
+// Restore заменяет файл базы dbFile снимком snapshot. Снимок сначала
+// проверяется: он должен быть целым и со схемой не новее текущей.
+// Прежняя база вместе с журналами сохраняется рядом с суффиксом
+// .before-restore. Если с dbFile работает сервер, Restore возвращает
+// db.ErrLocked.
+func Restore(snapshot, dbFile string) error {
+	unlock, err := db.Lock(dbFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := db.CheckSnapshot(snapshot); err != nil {
+		return err
+	}
+
+	tmp := dbFile + ".restore"
+	if err := copyFile(snapshot, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if _, err := os.Stat(dbFile); err == nil {
+		if err := saveBeforeRestore(dbFile); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dbFile); err != nil {
+		return fmt.Errorf("error replacing database: %w", err)
+	}
+
+	// Старый снимок доводится до текущей схемы сразу, а не при запуске сервера.
+	restored, err := db.Open(dbFile)
+	if err != nil {
+		return err
+	}
+	return restored.Close()
+}
+
+// journals — суффиксы файлов, которые SQLite ведёт рядом с базой.
+var journals = []string{"-wal", "-shm", "-journal"}
+
+// saveBeforeRestore переносит базу dbFile и её журналы под имя с суффиксом
+// .before-restore. Журналы могут хранить ещё не перенесённые в базу
+// изменения, поэтому без них копия была бы неполной. Журналы от прошлого
+// восстановления удаляются, чтобы SQLite не применил их к новой копии.
+func saveBeforeRestore(dbFile string) error {
+	saved := dbFile + ".before-restore"
+	for _, journal := range journals {
+		if err := os.Remove(saved + journal); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(dbFile, saved); err != nil {
+		return err
+	}
+	for _, journal := range journals {
+		if err := os.Rename(dbFile+journal, saved+journal); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go1f/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openRepo(t *testing.T, file string) *db.Repository {
+	database, err := db.Open(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	return db.NewRepository(database)
+}
+
+func TestSnapshotAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	repo := openRepo(t, filepath.Join(dir, "scheduler.db"))
+	_, err := repo.AddTask(&db.Task{Date: "20240101", Title: "Полить цветы"})
+	require.NoError(t, err)
+
+	backups := filepath.Join(dir, "backups")
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		_, err := Snapshot(repo, backups, start.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(backups, "notes.txt"), nil, 0o644))
+
+	require.NoError(t, Rotate(backups, 2))
+	files, err := Snapshots(backups)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(backups, "scheduler-20240101-140000.db"),
+		filepath.Join(backups, "scheduler-20240101-150000.db"),
+	}, files)
+
+	version, err := db.CheckSnapshot(files[1])
+	require.NoError(t, err)
+	assert.Equal(t, db.LatestSchemaVersion(), version)
+
+	snapshot := openRepo(t, files[1])
+	tasks, err := snapshot.Tasks(10, db.TaskFilter{})
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "scheduler.db")
+	repo := openRepo(t, dbFile)
+	_, err := repo.AddTask(&db.Task{Date: "20240101", Title: "До снимка"})
+	require.NoError(t, err)
+
+	snapshot, err := Snapshot(repo, dir, time.Now())
+	require.NoError(t, err)
+	_, err = repo.AddTask(&db.Task{Date: "20240102", Title: "После снимка"})
+	require.NoError(t, err)
+
+	broken := filepath.Join(dir, "broken.db")
+	require.NoError(t, os.WriteFile(broken, []byte("not a database"), 0o644))
+	assert.Error(t, Restore(broken, dbFile))
+
+	newer := filepath.Join(dir, "newer.db")
+	require.NoError(t, repo.Backup(newer))
+	raw, err := db.Connect(newer)
+	require.NoError(t, err)
+	_, err = raw.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, 'future', '')`,
+		db.LatestSchemaVersion()+1)
+	require.NoError(t, err)
+	raw.Close()
+	assert.Error(t, Restore(newer, dbFile))
+
+	unlock, err := db.Lock(dbFile)
+	require.NoError(t, err)
+	assert.ErrorIs(t, Restore(snapshot, dbFile), db.ErrLocked)
+	require.NoError(t, unlock())
+
+	// Журнал прежней базы переносится к её копии, а устаревший журнал
+	// от прошлого восстановления удаляется.
+	require.NoError(t, os.WriteFile(dbFile+"-wal", []byte("wal"), 0o644))
+	require.NoError(t, os.WriteFile(dbFile+".before-restore-journal", []byte("old"), 0o644))
+	require.NoError(t, Restore(snapshot, dbFile))
+
+	restored := openRepo(t, dbFile)
+	tasks, err := restored.Tasks(10, db.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "До снимка", tasks[0].Title)
+
+	_, err = os.Stat(dbFile + ".before-restore")
+	assert.NoError(t, err)
+	wal, err := os.ReadFile(dbFile + ".before-restore-wal")
+	require.NoError(t, err)
+	assert.Equal(t, "wal", string(wal))
+	_, err = os.Stat(dbFile + ".before-restore-journal")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go1f/pkg/backup"
+	"go1f/pkg/db"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultBackupDir      = "backups"
+	defaultBackupInterval = 24 * time.Hour
+	defaultBackupKeep     = 7
+)
+
+func backupCmd(args []string, out io.Writer) error {
+	keep, err := backupKeep()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("dir", backupDir(), "directory for snapshots")
+	flags.IntVar(&keep, "keep", keep, "number of latest snapshots to keep, 0 keeps all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := db.Connect(dsn())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	path, err := backup.Snapshot(db.NewRepository(database), *dir, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "backup saved to %s\n", path)
+
+	return backup.Rotate(*dir, keep)
+}
+
+func restoreCmd(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if os.Getenv("TODO_DSN") != "" || os.Getenv("TODO_TASKDIR") != "" {
+		return errors.New("restore works only with the SQLite database file")
+	}
+
+	dbFile := dsn()
+	if err := backup.Restore(args[0], dbFile); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s restored from %s\n", dbFile, args[0])
+	return nil
+}
+
+// scheduleBackups запускает снимки базы по расписанию, если задан
+// TODO_BACKUP_DIR. Интервал задаётся TODO_BACKUP_INTERVAL (например, 6h),
+// число хранимых снимков — TODO_BACKUP_KEEP.
+func scheduleBackups(repo *db.Repository) error {
+	dir := os.Getenv("TODO_BACKUP_DIR")
+	if dir == "" {
+		return nil
+	}
+
+	interval := defaultBackupInterval
+	if env := os.Getenv("TODO_BACKUP_INTERVAL"); env != "" {
+		var err error
+		if interval, err = time.ParseDuration(env); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid TODO_BACKUP_INTERVAL %q", env)
+		}
+	}
+	keep, err := backupKeep()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Резервные копии сохраняются в %s каждые %s\n", dir, interval)
+	go backup.Schedule(context.Background(), repo, dir, interval, keep)
+	return nil
+}
+
+func backupDir() string {
+	if dir := os.Getenv("TODO_BACKUP_DIR"); dir != "" {
+		return dir
+	}
+	return defaultBackupDir
+}
+
+func backupKeep() (int, error) {
+	env := os.Getenv("TODO_BACKUP_KEEP")
+	if env == "" {
+		return defaultBackupKeep, nil
+	}
+	keep, err := strconv.Atoi(env)
+	if err != nil || keep < 0 {
+		return 0, fmt.Errorf("invalid TODO_BACKUP_KEEP %q", env)
+	}
+	return keep, nil
+}
@@ -0,0 +1,106 @@
+// Package cli разбирает аргументы командной строки. Без аргументов
+// запускается веб-сервер, остальные команды обслуживают базу.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"go1f/pkg/db/filestore"
+	"go1f/pkg/server"
+	"io"
+	"os"
+)
+
+const usage = `usage:
+  taskmanager                         start the web server
+  taskmanager migrate status          show applied and pending migrations
+  taskmanager migrate up [-dry-run]   apply pending migrations
+  taskmanager backup [-dir DIR] [-keep N]
+                                      save a database snapshot to DIR
+                                      (TODO_BACKUP_DIR), keeping N latest
+  taskmanager restore FILE            replace the database with a snapshot
+  taskmanager import [-dry-run] FORMAT FILE
+                                      add tasks from a file; FORMAT is ics,
+                                      todotxt, todoist or trello
+  taskmanager export todotxt [FILE]   write all tasks in todo.txt format`
+
+var errUsage = errors.New(usage)
+
+// Run выполняет команду args (без имени программы), печатая результат в out.
+func Run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return serve()
+	}
+
+	switch args[0] {
+	case "migrate":
+		return migrate(args[1:], out)
+	case "backup":
+		return backupCmd(args[1:], out)
+	case "restore":
+		return restoreCmd(args[1:], out)
+	case "import":
+		return importCmd(args[1:], out)
+	case "export":
+		return exportCmd(args[1:], out)
+	case "help", "-h", "--help":
+		fmt.Fprintln(out, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+}
+
+// dsn возвращает адрес базы: URL PostgreSQL из TODO_DSN, а если он
+// не задан — путь к файлу SQLite из TODO_DBFILE.
+func dsn() string {
+	if dsn := os.Getenv("TODO_DSN"); dsn != "" {
+		return dsn
+	}
+	if file := os.Getenv("TODO_DBFILE"); file != "" {
+		return file
+	}
+	return server.DbFile
+}
+
+func serve() error {
+	store, closeStore, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	if repo, ok := store.(*db.Repository); ok {
+		if err := scheduleBackups(repo); err != nil {
+			return err
+		}
+	}
+	return server.StartServer(store)
+}
+
+// openStore открывает хранилище, с которым работает сервер: каталог задач
+// из TODO_TASKDIR или базу данных. Миграции базы применяются при открытии.
+func openStore() (db.Store, func() error, error) {
+	if dir := os.Getenv("TODO_TASKDIR"); dir != "" {
+		store, err := filestore.Open(dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening task directory: %w", err)
+		}
+		return store, func() error { return nil }, nil
+	}
+
+	// Пока сервер работает, восстановить базу из снимка нельзя.
+	unlock, err := db.Lock(dsn())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error locking database: %w", err)
+	}
+	database, err := db.Open(dsn())
+	if err != nil {
+		unlock()
+		return nil, nil, fmt.Errorf("error initializing database: %w", err)
+	}
+	return db.NewRepository(database), func() error {
+		defer unlock()
+		return database.Close()
+	}, nil
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"go1f/pkg/api"
+	"go1f/pkg/db"
+	"io"
+	"os"
+)
+
+// importCmd загружает задачи из файла во внешнем формате и печатает отчёт.
+func importCmd(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "show the report without saving tasks")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errUsage
+	}
+	format, path := flags.Arg(0), flags.Arg(1)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	store, closeStore, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	report, err := api.Import(store.WithActor("cli"), format, f, *dryRun)
+	if err != nil {
+		return err
+	}
+	printReport(out, report)
+	return nil
+}
+
+func printReport(out io.Writer, report *api.ImportReport) {
+	if report.DryRun {
+		fmt.Fprint(out, "dry run, nothing saved: ")
+	}
+	fmt.Fprintf(out, "imported %d, duplicates %d, skipped %d\n",
+		report.Imported, report.Duplicates, report.Skipped)
+	for _, n := range report.Notes {
+		fmt.Fprintf(out, "  %q: %s\n", n.Title, n.Note)
+	}
+	for _, task := range report.Tasks {
+		fmt.Fprintf(out, "  + %s %q", task.Date, task.Title)
+		if task.Repeat != "" {
+			fmt.Fprintf(out, " repeat %q", task.Repeat)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// exportCmd выгружает все задачи во внешнем формате в файл или, если файл
+// не указан, в out.
+func exportCmd(args []string, out io.Writer) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+
+	var save func(w io.Writer, store db.Store) error
+	switch format := args[0]; format {
+	case "todotxt":
+		save = api.ExportTodoTxt
+	default:
+		return fmt.Errorf("unknown export format %q\n%s", format, usage)
+	}
+
+	store, closeStore, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	if len(args) == 1 {
+		return save(out, store)
+	}
+
+	f, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	if err := save(f, store); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
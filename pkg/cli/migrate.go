@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"go1f/pkg/db"
+	"io"
+)
+
+func migrate(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	database, err := db.Connect(dsn())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "status":
+		return migrateStatus(database, out)
+	case "up":
+		flags := flag.NewFlagSet("migrate up", flag.ContinueOnError)
+		dryRun := flags.Bool("dry-run", false, "apply pending migrations and roll them back")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		return migrateUp(database, *dryRun, out)
+	}
+	return errUsage
+}
+
+func migrateStatus(database *sql.DB, out io.Writer) error {
+	list, err := db.Migrations(database)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, m := range list {
+		state := "applied " + m.AppliedAt
+		if !m.Applied() {
+			state = "pending"
+			pending++
+		}
+		fmt.Fprintf(out, "%4d  %-28s %s\n", m.Version, m.Name, state)
+	}
+	fmt.Fprintf(out, "%d pending migration(s)\n", pending)
+	return nil
+}
+
+func migrateUp(database *sql.DB, dryRun bool, out io.Writer) error {
+	applied, err := db.Migrate(database, dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "applied"
+	if dryRun {
+		verb = "would apply"
+	}
+	for _, m := range applied {
+		fmt.Fprintf(out, "%s %d  %s\n", verb, m.Version, m.Name)
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(out, "schema is up to date")
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package dates разбирает даты, которые вводит пользователь: формат задач
+// YYYYMMDD, ISO 8601, DD.MM.YYYY и относительные слова на русском и
+// английском («завтра», "next friday", "+3d"). Дата всегда приводится к
+// началу дня по UTC, а сохраняется в формате задач YYYYMMDD.
+package dates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format — формат, в котором даты хранятся в задачах.
+const Format = "20060102"
+
+// maxOffset — наибольшее число единиц в сдвиге вида "+N".
+const maxOffset = 400
+
+// layouts — явные форматы даты, от формата задач до ISO 8601 со временем.
+// У даты со временем берётся день, как он записан, без перевода в UTC.
+var layouts = []string{
+	Format,
+	"2.1.2006",
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+var explicit = regexp.MustCompile(`^(\d{1,2}\.\d{1,2}\.\d{4}|\d{4}-\d{2}-\d{2}(T[\d:.]+(Z|[+-]\d{2}:\d{2})?)?)$`)
+
+// Explicit сообщает, записана ли s как календарная дата в формате
+// DD.MM.YYYY или ISO 8601. Такой день может и не существовать: это
+// проверяет Parse. Поиск отличает так даты от обычных слов.
+func Explicit(s string) bool {
+	return explicit.MatchString(strings.TrimSpace(s))
+}
+
+// Normalize разбирает дату, как Parse, и возвращает её в формате задач.
+func Normalize(s string, now time.Time) (string, error) {
+	t, err := Parse(s, now)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(Format), nil
+}
+
+// Parse разбирает явную или относительную дату. Относительные даты
+// отсчитываются от дня now.
+func Parse(s string, now time.Time) (time.Time, error) {
+	return ParseFrom(s, now, now)
+}
+
+// ParseFrom разбирает дату, как Parse, но сдвиги "+N" и «через N …»
+// отсчитывает от дня base, а остальные относительные слова — от дня now.
+// Так отложенная задача сдвигается от своей даты, а «завтра» остаётся завтра.
+//
+// Понимаются:
+//   - YYYYMMDD, DD.MM.YYYY, YYYY-MM-DD и YYYY-MM-DDThh:mm[:ss][зона];
+//   - today, tomorrow, yesterday, сегодня, завтра, послезавтра, вчера;
+//   - next week, next month, next year и «через неделю», «через месяц», «через год»;
+//   - +Nd, +Nw, +Nm, +Ny (и +Nд, +Nн, +Nм, +Nг), in N days, через N дней и т. п.;
+//   - день недели: friday, fri, next friday, пятница, в пятницу,
+//     в следующую пятницу, пт — ближайший такой день после сегодняшнего.
+func ParseFrom(s string, base, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	base, today := day(base), day(now)
+
+	if isDigits(s) || Explicit(s) {
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+
+	expr := strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	if offset, ok := words[expr]; ok {
+		return offset.from(today), nil
+	}
+	if t, ok := shift(expr, base); ok {
+		return t, nil
+	}
+	if wd, ok := weekday(expr); ok {
+		diff := (int(wd) - int(today.Weekday()) + 7) % 7
+		if diff == 0 {
+			diff = 7
+		}
+		return today.AddDate(0, 0, diff), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// span — сдвиг на годы, месяцы и дни.
+type span struct{ years, months, days int }
+
+func (o span) from(t time.Time) time.Time {
+	return t.AddDate(o.years, o.months, o.days)
+}
+
+func (o span) times(n int) span {
+	return span{o.years * n, o.months * n, o.days * n}
+}
+
+var words = map[string]span{
+	"today":               {},
+	"сегодня":             {},
+	"tomorrow":            {days: 1},
+	"завтра":              {days: 1},
+	"day after tomorrow":  {days: 2},
+	"послезавтра":         {days: 2},
+	"yesterday":           {days: -1},
+	"вчера":               {days: -1},
+	"next week":           {days: 7},
+	"на следующей неделе": {days: 7},
+	"next month":          {months: 1},
+	"в следующем месяце":  {months: 1},
+	"next year":           {years: 1},
+	"в следующем году":    {years: 1},
+}
+
+// units — единицы сдвига: буквы для "+N" и слова во всех формах после числа.
+var units = map[string]span{
+	"d": {days: 1}, "д": {days: 1},
+	"w": {days: 7}, "н": {days: 7},
+	"m": {months: 1}, "м": {months: 1},
+	"y": {years: 1}, "г": {years: 1},
+
+	"day": {days: 1}, "days": {days: 1},
+	"week": {days: 7}, "weeks": {days: 7},
+	"month": {months: 1}, "months": {months: 1},
+	"year": {years: 1}, "years": {years: 1},
+
+	"день": {days: 1}, "дня": {days: 1}, "дней": {days: 1},
+	"неделю": {days: 7}, "недели": {days: 7}, "недель": {days: 7},
+	"месяц": {months: 1}, "месяца": {months: 1}, "месяцев": {months: 1},
+	"год": {years: 1}, "года": {years: 1}, "лет": {years: 1},
+}
+
+// shift разбирает сдвиг от base: "+3d", "in 3 days", «через 3 дня»,
+// «через неделю».
+func shift(expr string, base time.Time) (time.Time, bool) {
+	var count, unit string
+	if rest, ok := strings.CutPrefix(expr, "+"); ok {
+		i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return time.Time{}, false
+		}
+		count, unit = rest[:i], strings.TrimSpace(rest[i:])
+		if len([]rune(unit)) != 1 {
+			return time.Time{}, false
+		}
+	} else {
+		rest, ok := strings.CutPrefix(expr, "in ")
+		if !ok {
+			rest, ok = strings.CutPrefix(expr, "через ")
+		}
+		if !ok {
+			return time.Time{}, false
+		}
+		count, unit, ok = strings.Cut(rest, " ")
+		if !ok {
+			count, unit = "1", rest
+		}
+	}
+
+	u, ok := units[unit]
+	if !ok {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 || n > maxOffset {
+		return time.Time{}, false
+	}
+	return u.times(n).from(base), true
+}
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+	"sunday": time.Sunday, "sun": time.Sunday,
+
+	"понедельник": time.Monday, "пн": time.Monday,
+	"вторник": time.Tuesday, "вт": time.Tuesday,
+	"среда": time.Wednesday, "среду": time.Wednesday, "ср": time.Wednesday,
+	"четверг": time.Thursday, "чт": time.Thursday,
+	"пятница": time.Friday, "пятницу": time.Friday, "пт": time.Friday,
+	"суббота": time.Saturday, "субботу": time.Saturday, "сб": time.Saturday,
+	"воскресенье": time.Sunday, "вс": time.Sunday,
+}
+
+// weekday разбирает день недели с необязательными предлогом и словом
+// «следующий»: "next friday", «в следующую пятницу», «во вторник».
+func weekday(expr string) (time.Weekday, bool) {
+	for _, prefix := range []string{"next ", "в ", "во "} {
+		if rest, ok := strings.CutPrefix(expr, prefix); ok {
+			expr = rest
+			break
+		}
+	}
+	for _, next := range []string{"следующий ", "следующую ", "следующая ", "следующее "} {
+		if rest, ok := strings.CutPrefix(expr, next); ok {
+			expr = rest
+			break
+		}
+	}
+	wd, ok := weekdays[expr]
+	return wd, ok
+}
+
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,91 @@
+package dates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	// Четверг, время дня не влияет на результат.
+	now := time.Date(2026, 10, 15, 21, 30, 0, 0, time.UTC)
+	tbl := []struct {
+		input string
+		want  string
+	}{
+		{"20261201", "20261201"},
+		{"01.12.2026", "20261201"},
+		{"1.2.2027", "20270201"},
+		{"2026-12-01", "20261201"},
+		{"2026-12-01T23:30:00+03:00", "20261201"},
+		{"2026-12-01T08:00", "20261201"},
+		{"today", "20261015"},
+		{" Сегодня ", "20261015"},
+		{"завтра", "20261016"},
+		{"Tomorrow", "20261016"},
+		{"послезавтра", "20261017"},
+		{"yesterday", "20261014"},
+		{"next week", "20261022"},
+		{"через неделю", "20261022"},
+		{"next month", "20261115"},
+		{"+3d", "20261018"},
+		{"+2w", "20261029"},
+		{"+1m", "20261115"},
+		{"+1y", "20271015"},
+		{"+3д", "20261018"},
+		{"in 3 days", "20261018"},
+		{"через 3 дня", "20261018"},
+		{"через 5 дней", "20261020"},
+		{"через 2 месяца", "20261215"},
+		{"next friday", "20261016"},
+		{"friday", "20261016"},
+		{"thursday", "20261022"},
+		{"пятница", "20261016"},
+		{"в пятницу", "20261016"},
+		{"в следующую пятницу", "20261016"},
+		{"во вторник", "20261020"},
+		{"пн", "20261019"},
+		{"20240192", ""},
+		{"31.02.2026", ""},
+		{"2026-13-01", ""},
+		{"+0d", ""},
+		{"+401d", ""},
+		{"+3x", ""},
+		{"next someday", ""},
+		{"через много дней", ""},
+		{"когда-нибудь", ""},
+		{"", ""},
+	}
+	for _, v := range tbl {
+		got, err := Normalize(v.input, now)
+		if v.want == "" {
+			assert.Error(t, err, v.input)
+			continue
+		}
+		assert.NoError(t, err, v.input)
+		assert.Equal(t, v.want, got, v.input)
+	}
+}
+
+func TestParseFrom(t *testing.T) {
+	now := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	base := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	// Сдвиг отсчитывается от base, остальные слова — от now.
+	next, err := ParseFrom("+1w", base, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "20261108", next.Format(Format))
+	next, err = ParseFrom("завтра", base, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "20261016", next.Format(Format))
+}
+
+func TestExplicit(t *testing.T) {
+	for _, s := range []string{"01.12.2026", "1.2.2026", "31.02.2026", "2026-12-01", "2026-12-01T10:00:00Z"} {
+		assert.True(t, Explicit(s), s)
+	}
+	for _, s := range []string{"20261201", "завтра", "18:00", "1-2", "e-mail", "01.12"} {
+		assert.False(t, Explicit(s), s)
+	}
+}
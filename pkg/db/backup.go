@@ -0,0 +1,62 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrBackupUnsupported возвращается, если хранилище не умеет делать снимки.
+var ErrBackupUnsupported = errors.New("backup is not supported by this storage")
+
+// Backuper — хранилище, которое умеет сохранять согласованный снимок базы.
+type Backuper interface {
+	Backup(path string) error
+}
+
+// Backup записывает в path согласованный снимок базы SQLite. Снимок
+// делается в одной транзакции чтения, поэтому сервер может продолжать
+// работу. Для PostgreSQL используйте pg_dump.
+func (r *Repository) Backup(path string) error {
+	if r.dialect != sqlite {
+		return ErrBackupUnsupported
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+	_, err := r.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// CheckSnapshot проверяет, что файл path — целая база SQLite со схемой,
+// которую можно довести до текущей миграциями, и возвращает версию схемы.
+func CheckSnapshot(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	snapshot, err := Connect(path)
+	if err != nil {
+		return 0, err
+	}
+	defer snapshot.Close()
+
+	var result string
+	if err := snapshot.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("%s is not a valid database: %w", path, err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("%s is corrupted: %s", path, result)
+	}
+
+	version, err := SchemaVersion(snapshot)
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("%s has no schema version", path)
+	}
+	if version > LatestSchemaVersion() {
+		return 0, fmt.Errorf("%s has schema version %d, newer than supported %d", path, version, LatestSchemaVersion())
+	}
+	return version, nil
+}
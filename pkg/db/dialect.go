@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// dialect — диалект SQL базы. Запросы пишутся для SQLite, а для
+// PostgreSQL переводятся через pgQuerier.
+type dialect int
+
+const (
+	sqlite dialect = iota
+	postgres
+)
+
+func dialectOf(db *sql.DB) dialect {
+	if _, ok := db.Driver().(*pq.Driver); ok {
+		return postgres
+	}
+	return sqlite
+}
+
+// isPostgresDSN сообщает, указывает ли dsn на PostgreSQL, а не на файл SQLite.
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// pgQuerier выполняет запросы в PostgreSQL, заменяя плейсхолдеры ? на $1, $2...
+type pgQuerier struct {
+	q querier
+}
+
+func (p pgQuerier) Exec(query string, args ...any) (sql.Result, error) {
+	return p.q.Exec(rebind(query), args...)
+}
+
+func (p pgQuerier) Query(query string, args ...any) (*sql.Rows, error) {
+	return p.q.Query(rebind(query), args...)
+}
+
+func (p pgQuerier) QueryRow(query string, args ...any) *sql.Row {
+	return p.q.QueryRow(rebind(query), args...)
+}
+
+func wrap(q querier, d dialect) querier {
+	if d == postgres {
+		return pgQuerier{q: q}
+	}
+	return q
+}
+
+// rebind нумерует плейсхолдеры ? вне строковых литералов.
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// insert выполняет INSERT и возвращает идентификатор новой строки.
+// PostgreSQL не поддерживает LastInsertId, поэтому там используется RETURNING.
+func insert(q querier, query string, args ...any) (int64, error) {
+	if _, ok := q.(pgQuerier); ok {
+		var id int64
+		err := q.QueryRow(query+` RETURNING id`, args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// forUpdate дописывает к SELECT блокировку строки. В SQLite транзакция и так
+// держит блокировку на запись всей базы, а в PostgreSQL без неё параллельные
+// транзакции упирались бы в проверку версии и повторялись.
+func forUpdate(q querier, query string) string {
+	if _, ok := q.(pgQuerier); ok {
+		return query + ` FOR UPDATE`
+	}
+	return query
+}
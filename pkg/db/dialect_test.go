@@ -0,0 +1,14 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, `SELECT * FROM scheduler WHERE id = $1 AND version = $2`,
+		rebind(`SELECT * FROM scheduler WHERE id = ? AND version = ?`))
+	assert.Equal(t, `UPDATE scheduler SET title = '?', date = $1`,
+		rebind(`UPDATE scheduler SET title = '?', date = ?`))
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrFeedNotFound возвращается, если ленты календаря с таким идентификатором
+// или токеном нет.
+var ErrFeedNotFound = errors.New("feed not found")
+
+// Типы записей ленты календаря.
+const (
+	FeedEvents = "event"
+	FeedTodos  = "todo"
+)
+
+// Feed — лента календаря. Календарные клиенты не умеют входить по паролю,
+// поэтому лента открывается по своему секретному токену; в базе хранится
+// только его хеш.
+type Feed struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	TokenHash string `json:"-"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (r *Repository) Feeds() ([]*Feed, error) {
+	rows, err := r.q().Query(`SELECT id, name, kind, token_hash, created_at FROM feed ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := make([]*Feed, 0)
+	for rows.Next() {
+		feed, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, rows.Err()
+}
+
+// FeedByToken возвращает ленту по хешу её токена.
+func (r *Repository) FeedByToken(tokenHash string) (*Feed, error) {
+	query := `SELECT id, name, kind, token_hash, created_at FROM feed WHERE token_hash = ?`
+	feed, err := scanFeed(r.q().QueryRow(query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, ErrFeedNotFound
+	}
+	return feed, err
+}
+
+func (r *Repository) AddFeed(feed *Feed) (int64, error) {
+	feed.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	query := `INSERT INTO feed (name, kind, token_hash, created_at) VALUES (?, ?, ?, ?)`
+	return insert(r.q(), query, feed.Name, feed.Kind, feed.TokenHash, feed.CreatedAt)
+}
+
+func (r *Repository) DeleteFeed(id string) error {
+	res, err := r.q().Exec(`DELETE FROM feed WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrFeedNotFound
+	}
+	return nil
+}
+
+func scanFeed(row interface{ Scan(...any) error }) (*Feed, error) {
+	feed := &Feed{}
+	if err := row.Scan(&feed.ID, &feed.Name, &feed.Kind, &feed.TokenHash, &feed.CreatedAt); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
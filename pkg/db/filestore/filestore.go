@@ -0,0 +1,655 @@
+// Package filestore хранит задачи в каталоге: по файлу Markdown на задачу
+// с датой, правилом повторения и статусом во front matter и комментарием
+// в теле. Такой каталог удобно держать под контролем версий.
+//
+//	---
+//	title: Полить цветы
+//	date: "20240101"
+//	repeat: d 3
+//	status: todo
+//	version: 2
+//	---
+//	Комментарий к задаче.
+//
+// Файлы задач называются <id>.md. История изменений, шаблоны, ленты
+// календаря, сохранённые поиски и ссылки на импортированные записи лежат
+// в подкаталоге .scheduler. Все операции выполняются под блокировкой файла
+// .scheduler/lock. Изменения транзакции сначала записываются во временные
+// файлы и журнал .scheduler/journal.json, а затем переносятся на место,
+// так что на диск попадает либо вся транзакция, либо ничего (см. journal).
+//
+// Перед каждой операцией каталог перечитывается, поэтому файлы можно
+// править вручную и при запущенном сервере. Изменённая вручную задача
+// получает новую версию и ревизию от имени "external".
+package filestore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"go1f/pkg/db/memstore"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	metaDir       = ".scheduler"
+	historyFile   = "history.jsonl"
+	templatesFile = "templates.json"
+	feedsFile     = "feeds.json"
+	refsFile      = "refs.json"
+	searchesFile  = "searches.json"
+	journalFile   = "journal.json"
+	lockFileName  = "lock"
+
+	// ExternalActor — автор ревизий для изменений, сделанных в обход хранилища.
+	ExternalActor = "external"
+)
+
+// Open открывает каталог задач dir, создавая его при необходимости.
+func Open(dir string) (*memstore.Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, metaDir), 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, metaDir, lockFileName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &backend{
+		dir:       dir,
+		lock:      lock,
+		files:     make(map[string]os.FileInfo),
+		templates: jsonFile{name: templatesFile},
+		feeds:     jsonFile{name: feedsFile},
+		refs:      jsonFile{name: refsFile},
+		searches:  jsonFile{name: searchesFile},
+	}
+	store, err := memstore.NewWithBackend(b)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// changed сообщает, изменился ли файл с тех пор, как о нём было известно old.
+// Время изменения у многих файловых систем грубое, но хранилище заменяет файлы
+// переименованием, так что его записи отличаются ещё и самим файлом.
+func changed(old, info os.FileInfo) bool {
+	return old == nil || !os.SameFile(old, info) ||
+		!old.ModTime().Equal(info.ModTime()) || old.Size() != info.Size()
+}
+
+type backend struct {
+	dir  string
+	lock *os.File
+
+	files     map[string]os.FileInfo // файлы задач в том виде, в каком их прочитали или записали
+	history   int64                  // сколько байт истории уже прочитано
+	templates jsonFile
+	feeds     jsonFile
+	refs      jsonFile
+	searches  jsonFile
+}
+
+func (b *backend) Acquire(st *memstore.State) error {
+	if err := lockFile(b.lock); err != nil {
+		return fmt.Errorf("error locking %s: %w", b.dir, err)
+	}
+	err := b.replayJournal()
+	if err == nil {
+		err = b.sync(st)
+	}
+	if err != nil {
+		unlockFile(b.lock)
+		return err
+	}
+	return nil
+}
+
+func (b *backend) Release() {
+	unlockFile(b.lock)
+}
+
+// Save записывает изменения через журнал. Пока журнал не записан, на диске
+// ничего не меняется; после этого транзакция считается сохранённой, и если
+// перенести файлы на место не удалось, это доделает replayJournal.
+func (b *backend) Save(before, after *memstore.State) error {
+	offset, err := b.historySize()
+	if err != nil {
+		return err
+	}
+	j := &journal{HistoryOffset: offset}
+	err = b.stage(j, before, after)
+	if err == nil {
+		err = b.writeJournal(j)
+	}
+	if err != nil {
+		j.discard(b.dir)
+		return err
+	}
+	b.history = offset + int64(len(j.History))
+
+	if err := j.apply(b.dir); err != nil {
+		log.Printf("filestore: %v; the transaction will be completed by the next operation", err)
+		return nil
+	}
+	return b.refresh(j)
+}
+
+// stage записывает во временные файлы всё, что изменилось между before и after.
+func (b *backend) stage(j *journal, before, after *memstore.State) error {
+	for id, task := range after.Tasks {
+		if old, ok := before.Tasks[id]; ok && *old == *task {
+			continue
+		}
+		if err := j.stage(b.dir, id+".md", encodeTask(task)); err != nil {
+			return err
+		}
+	}
+	for id := range before.Tasks {
+		if _, ok := after.Tasks[id]; !ok {
+			j.Removes = append(j.Removes, id+".md")
+		}
+	}
+
+	var history bytes.Buffer
+	enc := json.NewEncoder(&history)
+	for _, rev := range after.Revisions[len(before.Revisions):] {
+		if err := enc.Encode(rev); err != nil {
+			return err
+		}
+	}
+	j.History = history.String()
+
+	for _, f := range []struct {
+		file    *jsonFile
+		changed bool
+		list    func() any
+	}{
+		{&b.templates, !same(before.Templates, after.Templates), func() any { return templateList(after.Templates) }},
+		{&b.feeds, !same(before.Feeds, after.Feeds), func() any { return feedList(after.Feeds) }},
+		{&b.refs, !same(before.Refs, after.Refs), func() any { return refList(after.Refs) }},
+		{&b.searches, !same(before.Searches, after.Searches), func() any { return searchList(after.Searches) }},
+	} {
+		if !f.changed {
+			continue
+		}
+		data, err := json.MarshalIndent(f.list(), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := j.stage(b.dir, filepath.Join(metaDir, f.file.name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refresh запоминает файлы, записанные по журналу, чтобы sync не принял
+// их за изменения в обход хранилища.
+func (b *backend) refresh(j *journal) error {
+	for _, r := range j.Renames {
+		info, err := os.Stat(filepath.Join(b.dir, r.To))
+		if err != nil {
+			return err
+		}
+		if id, ok := taskFileID(r.To); ok {
+			b.files[id] = info
+			continue
+		}
+		for _, f := range []*jsonFile{&b.templates, &b.feeds, &b.refs, &b.searches} {
+			if r.To == filepath.Join(metaDir, f.name) {
+				f.info = info
+			}
+		}
+	}
+	for _, name := range j.Removes {
+		id, _ := taskFileID(name)
+		delete(b.files, id)
+	}
+	return nil
+}
+
+// sync приводит состояние в памяти к содержимому каталога: дочитывает
+// историю и шаблоны, записанные другими процессами, и сверяет файлы задач.
+func (b *backend) sync(st *memstore.State) error {
+	if err := b.readHistory(st); err != nil {
+		return err
+	}
+	if err := b.readTemplates(st); err != nil {
+		return err
+	}
+	if err := b.readFeeds(st); err != nil {
+		return err
+	}
+	if err := b.readRefs(st); err != nil {
+		return err
+	}
+	if err := b.readSearches(st); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+
+	var external []*db.Revision
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		id, ok := taskFileID(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		seen[id] = true
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !changed(b.files[id], info) {
+			continue
+		}
+		b.files[id] = info
+
+		task, err := readTask(b.taskPath(id), id)
+		if err != nil {
+			log.Printf("filestore: skipping %s: %v", entry.Name(), err)
+			continue
+		}
+
+		rev, err := b.reconcile(st, task)
+		if err != nil {
+			return err
+		}
+		if rev != nil {
+			external = append(external, rev)
+		}
+	}
+
+	for id, task := range st.Tasks {
+		if seen[id] {
+			continue
+		}
+		delete(st.Tasks, id)
+		delete(b.files, id)
+		if last := lastRevision(st, id); last == nil || last.Action != db.ActionDelete {
+			log.Printf("filestore: task %s was deleted externally", id)
+			external = append(external, addRevision(st, db.ActionDelete, id, task, nil))
+		}
+	}
+
+	return b.appendHistory(external)
+}
+
+// reconcile учитывает прочитанный файл задачи. Файл с более новой версией
+// записан хранилищем в другом процессе и принимается как есть. Файл с прежней
+// версией, но другим содержимым исправлен вручную: задача получает новую
+// версию, а в историю пишется ревизия.
+func (b *backend) reconcile(st *memstore.State, task *db.Task) (*db.Revision, error) {
+	id := task.ID
+	if n, _ := strconv.ParseInt(id, 10, 64); n > st.LastID {
+		st.LastID = n
+	}
+
+	current, ok := st.Tasks[id]
+	switch {
+	case !ok && task.Version > 0, ok && task.Version > current.Version:
+		st.Tasks[id] = task
+		return nil, nil
+	case ok && sameContent(current, task):
+		return nil, nil
+	}
+
+	var rev *db.Revision
+	if ok {
+		log.Printf("filestore: task %s was edited externally", id)
+		task.Version = current.Version + 1
+		rev = addRevision(st, db.ActionUpdate, id, current, task)
+	} else {
+		log.Printf("filestore: task %s was added externally", id)
+		task.Version = 1
+		rev = addRevision(st, db.ActionInsert, id, nil, task)
+	}
+	st.Tasks[id] = task
+	return rev, b.writeTask(task)
+}
+
+func sameContent(a, b *db.Task) bool {
+	return a.Date == b.Date && a.Title == b.Title && a.Comment == b.Comment &&
+		a.Repeat == b.Repeat && a.Status == b.Status && a.Anchor == b.Anchor
+}
+
+func lastRevision(st *memstore.State, id string) *db.Revision {
+	for i := len(st.Revisions) - 1; i >= 0; i-- {
+		if st.Revisions[i].TaskID == id {
+			return st.Revisions[i]
+		}
+	}
+	return nil
+}
+
+func addRevision(st *memstore.State, action, id string, before, after *db.Task) *db.Revision {
+	st.LastRevID++
+	rev := &db.Revision{
+		ID:        strconv.FormatInt(st.LastRevID, 10),
+		TaskID:    id,
+		Action:    action,
+		Actor:     ExternalActor,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if before != nil {
+		t := *before
+		rev.Before = &t
+	}
+	if after != nil {
+		t := *after
+		rev.After = &t
+	}
+	st.Revisions = append(st.Revisions, rev)
+	return rev
+}
+
+func (b *backend) taskPath(id string) string {
+	return filepath.Join(b.dir, id+".md")
+}
+
+func taskFileID(name string) (string, bool) {
+	id, ok := strings.CutSuffix(name, ".md")
+	if !ok || id == "" {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(id, 10, 63); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+func (b *backend) writeTask(task *db.Task) error {
+	path := b.taskPath(task.ID)
+	if err := writeFileAtomic(path, encodeTask(task)); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	b.files[task.ID] = info
+	return nil
+}
+
+// readHistory дочитывает ревизии, добавленные в историю после прошлого чтения.
+func (b *backend) readHistory(st *memstore.State) error {
+	f, err := os.Open(filepath.Join(b.dir, metaDir, historyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(b.history, io.SeekStart); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(f)
+	for {
+		rev := &db.Revision{}
+		if err := dec.Decode(rev); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error reading history: %w", err)
+		}
+		st.Revisions = append(st.Revisions, rev)
+		if n, _ := strconv.ParseInt(rev.ID, 10, 64); n > st.LastRevID {
+			st.LastRevID = n
+		}
+		if n, _ := strconv.ParseInt(rev.TaskID, 10, 64); n > st.LastID {
+			st.LastID = n
+		}
+	}
+	b.history += dec.InputOffset()
+	return nil
+}
+
+// historySize возвращает размер файла истории. Он может быть больше
+// прочитанного b.history на завершающий перевод строки.
+func (b *backend) historySize() (int64, error) {
+	info, err := os.Stat(filepath.Join(b.dir, metaDir, historyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (b *backend) appendHistory(revisions []*db.Revision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	path := filepath.Join(b.dir, metaDir, historyFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rev := range revisions {
+		if err := enc.Encode(rev); err != nil {
+			return err
+		}
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	b.history += int64(buf.Len())
+	return nil
+}
+
+// jsonFile — файл со списком объектов (шаблонов, лент и т. п.), который
+// перезаписывается целиком при каждом изменении.
+type jsonFile struct {
+	name string
+	info os.FileInfo
+}
+
+// read читает список в v, если файл изменился с прошлого чтения или записи.
+func (f *jsonFile) read(dir string, v any) (bool, error) {
+	path := filepath.Join(dir, metaDir, f.name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !changed(f.info, info) {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("error reading %s: %w", f.name, err)
+	}
+	f.info = info
+	return true, nil
+}
+
+func (b *backend) readTemplates(st *memstore.State) error {
+	var templates []*db.Template
+	if ok, err := b.templates.read(b.dir, &templates); !ok || err != nil {
+		return err
+	}
+
+	st.Templates = make(map[string]*db.Template, len(templates))
+	for _, tpl := range templates {
+		st.Templates[tpl.ID] = tpl
+		st.LastTplID = max(st.LastTplID, parseID(tpl.ID))
+	}
+	return nil
+}
+
+func (b *backend) readFeeds(st *memstore.State) error {
+	var feeds []*storedFeed
+	if ok, err := b.feeds.read(b.dir, &feeds); !ok || err != nil {
+		return err
+	}
+
+	st.Feeds = make(map[string]*db.Feed, len(feeds))
+	for _, f := range feeds {
+		feed := f.Feed
+		feed.TokenHash = f.TokenHash
+		st.Feeds[feed.ID] = &feed
+		st.LastFeedID = max(st.LastFeedID, parseID(feed.ID))
+	}
+	return nil
+}
+
+func (b *backend) readRefs(st *memstore.State) error {
+	var refs []*db.ExternalRef
+	if ok, err := b.refs.read(b.dir, &refs); !ok || err != nil {
+		return err
+	}
+
+	st.Refs = make(map[string]*db.ExternalRef, len(refs))
+	for _, ref := range refs {
+		st.Refs[memstore.RefKey(ref.Source, ref.Ref)] = ref
+	}
+	return nil
+}
+
+func (b *backend) readSearches(st *memstore.State) error {
+	var searches []*db.SavedSearch
+	if ok, err := b.searches.read(b.dir, &searches); !ok || err != nil {
+		return err
+	}
+
+	st.Searches = make(map[string]*db.SavedSearch, len(searches))
+	for _, search := range searches {
+		st.Searches[search.ID] = search
+		st.LastSearchID = max(st.LastSearchID, parseID(search.ID))
+	}
+	return nil
+}
+
+// storedFeed сохраняет хеш токена, который в ответах API не показывается.
+type storedFeed struct {
+	db.Feed
+	TokenHash string `json:"token_hash"`
+}
+
+func templateList(templates map[string]*db.Template) []*db.Template {
+	list := make([]*db.Template, 0, len(templates))
+	for _, tpl := range templates {
+		list = append(list, tpl)
+	}
+	sort.Slice(list, func(i, j int) bool { return parseID(list[i].ID) < parseID(list[j].ID) })
+	return list
+}
+
+func feedList(feeds map[string]*db.Feed) []*storedFeed {
+	list := make([]*storedFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		list = append(list, &storedFeed{Feed: *feed, TokenHash: feed.TokenHash})
+	}
+	sort.Slice(list, func(i, j int) bool { return parseID(list[i].ID) < parseID(list[j].ID) })
+	return list
+}
+
+func refList(refs map[string]*db.ExternalRef) []*db.ExternalRef {
+	list := make([]*db.ExternalRef, 0, len(refs))
+	for _, ref := range refs {
+		list = append(list, ref)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Source != list[j].Source {
+			return list[i].Source < list[j].Source
+		}
+		return list[i].Ref < list[j].Ref
+	})
+	return list
+}
+
+func searchList(searches map[string]*db.SavedSearch) []*db.SavedSearch {
+	list := make([]*db.SavedSearch, 0, len(searches))
+	for _, search := range searches {
+		list = append(list, search)
+	}
+	sort.Slice(list, func(i, j int) bool { return parseID(list[i].ID) < parseID(list[j].ID) })
+	return list
+}
+
+func parseID(id string) int64 {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
+}
+
+// same сообщает, совпадают ли неизменяемые объекты в двух состояниях.
+func same[T any](a, b map[string]*T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, v := range a {
+		if b[id] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileAtomic записывает файл через временный файл в том же каталоге,
+// чтобы читатели видели либо старое, либо новое содержимое целиком.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := writeTemp(filepath.Dir(path), data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeTemp записывает data во временный файл каталога dir и возвращает его путь.
+func writeTemp(dir string, data []byte) (string, error) {
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
@@ -0,0 +1,213 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go1f/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+
+	id, err := store.AddTask(&db.Task{Date: "20240101", Title: "Полить цветы", Comment: "Кактус\nне трогать", Repeat: "d 3"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	data, err := os.ReadFile(filepath.Join(dir, "1.md"))
+	require.NoError(t, err)
+	assert.Equal(t, `---
+title: Полить цветы
+date: "20240101"
+repeat: d 3
+status: todo
+version: 1
+---
+Кактус
+не трогать
+`, string(data))
+
+	require.NoError(t, store.CompleteTask("1", 1, func(task *db.Task) (string, error) {
+		return "20240104", nil
+	}))
+	require.NoError(t, store.UpdateDate("20240106", "1"))
+	_, err = store.AddTemplate(&db.Template{Name: "Уборка", Tasks: []*db.TemplateTask{{Title: "Пылесос"}}})
+	require.NoError(t, err)
+	_, err = store.AddFeed(&db.Feed{Name: "Дом", Kind: db.FeedEvents, TokenHash: "abc"})
+	require.NoError(t, err)
+	_, err = store.AddSavedSearch(&db.SavedSearch{Name: "Дом", Query: "tag:дом"})
+	require.NoError(t, err)
+
+	// Новое хранилище на том же каталоге видит всё, что записано на диск.
+	reopened, err := Open(dir)
+	require.NoError(t, err)
+
+	task, err := reopened.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "20240106", task.Date)
+	assert.Equal(t, "20240104", task.Anchor)
+	assert.Equal(t, "Кактус\nне трогать", task.Comment)
+	assert.Equal(t, int64(3), task.Version)
+
+	history, err := reopened.History("1")
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+
+	templates, err := reopened.Templates()
+	require.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	feed, err := reopened.FeedByToken("abc")
+	require.NoError(t, err)
+	assert.Equal(t, "Дом", feed.Name)
+
+	saved, err := reopened.GetSavedSearch("1")
+	require.NoError(t, err)
+	assert.Equal(t, "tag:дом", saved.Query)
+
+	id, err = reopened.AddTask(&db.Task{Date: "20240105", Title: "Вторая"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+
+	// Первое хранилище подхватывает задачу, созданную вторым.
+	task, err = store.GetTask("2")
+	require.NoError(t, err)
+	assert.Equal(t, "Вторая", task.Title)
+
+	require.NoError(t, store.DeleteTask("2", 0))
+	_, err = reopened.GetTask("2")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	history, err = reopened.History("2")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestExternalEdits(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+
+	_, err = store.AddTask(&db.Task{Date: "20240101", Title: "Купить хлеб"})
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "1.md")
+	edit := "---\ntitle: Купить хлеб и молоко\ndate: 20240102\nversion: 1\n---\n"
+	require.NoError(t, os.WriteFile(path, []byte(edit), 0o644))
+	// Время изменения файла может совпасть с записанным хранилищем.
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+
+	task, err := store.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Купить хлеб и молоко", task.Title)
+	assert.Equal(t, "20240102", task.Date)
+	assert.Equal(t, db.StatusTodo, task.Status)
+	assert.Equal(t, int64(2), task.Version)
+
+	// Клиент с устаревшей версией получает конфликт.
+	task.Version = 1
+	assert.ErrorIs(t, store.UpdateTask(task), db.ErrConflict)
+
+	history, err := store.History("1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, db.ActionUpdate, history[1].Action)
+	assert.Equal(t, ExternalActor, history[1].Actor)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "7.md"), []byte("---\ntitle: Вручную\ndate: \"20240103\"\n---\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.md"), []byte("не задача"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "8.md"), []byte("без заголовка"), 0o644))
+	require.NoError(t, os.Remove(path))
+
+	tasks, err := store.Tasks(10, db.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "7", tasks[0].ID)
+	assert.Equal(t, int64(1), tasks[0].Version)
+
+	id, err := store.AddTask(&db.Task{Date: "20240104", Title: "После ручной"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), id)
+
+	history, err = store.History("1")
+	require.NoError(t, err)
+	assert.Equal(t, db.ActionDelete, history[len(history)-1].Action)
+}
+
+func TestConcurrentStores(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Open(dir)
+	require.NoError(t, err)
+	_, err = first.AddTask(&db.Task{Date: "20240101", Title: "Зарядка", Repeat: "d 1"})
+	require.NoError(t, err)
+	second, err := Open(dir)
+	require.NoError(t, err)
+
+	const parallel = 10
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func(store db.Store) {
+			defer wg.Done()
+			assert.NoError(t, store.CompleteTask("1", 0, func(task *db.Task) (string, error) {
+				date, _ := time.Parse("20060102", task.Date)
+				return date.AddDate(0, 0, 1).Format("20060102"), nil
+			}))
+		}([]db.Store{first, second}[i%2])
+	}
+	wg.Wait()
+
+	task, err := first.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "20240111", task.Date)
+	assert.Equal(t, int64(1+parallel), task.Version)
+
+	history, err := second.History("1")
+	require.NoError(t, err)
+	assert.Len(t, history, 1+parallel)
+}
+
+func TestInterruptedTransaction(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	require.NoError(t, err)
+
+	// Каталог на месте файла второй задачи не даёт перенести его из журнала.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2.md", "blocker"), 0o755))
+	tx, err := store.Begin()
+	require.NoError(t, err)
+	_, err = tx.AddTask(&db.Task{Date: "20240101", Title: "Первая"})
+	require.NoError(t, err)
+	_, err = tx.AddTask(&db.Task{Date: "20240102", Title: "Вторая"})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.FileExists(t, filepath.Join(dir, metaDir, journalFile))
+
+	// Следующая операция доводит транзакцию до конца, а не принимает
+	// её файлы за правку вручную.
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "2.md")))
+	reopened, err := Open(dir)
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, metaDir, journalFile))
+
+	for _, s := range []db.Store{reopened, store} {
+		task, err := s.GetTask("2")
+		require.NoError(t, err)
+		assert.Equal(t, "Вторая", task.Title)
+
+		for _, id := range []string{"1", "2"} {
+			history, err := s.History(id)
+			require.NoError(t, err)
+			require.Len(t, history, 1)
+			assert.Equal(t, db.ActionInsert, history[0].Action)
+			assert.NotEqual(t, ExternalActor, history[0].Actor)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// journal — изменения одной транзакции. Save записывает новые файлы во
+// временные, затем атомарно записывает журнал и только после этого
+// переносит файлы на место. Журнал, оставшийся после сбоя, доводится до
+// конца при следующей операции, поэтому на диске оказывается либо вся
+// транзакция, либо ничего из неё. Пути указаны относительно каталога задач.
+type journal struct {
+	Renames []rename `json:"renames,omitempty"`
+	Removes []string `json:"removes,omitempty"`
+	// HistoryOffset — размер истории до транзакции, History — её ревизии.
+	HistoryOffset int64  `json:"history_offset"`
+	History       string `json:"history,omitempty"`
+}
+
+// rename заменяет файл To временным файлом From.
+type rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// stage записывает data во временный файл рядом с name.
+func (j *journal) stage(dir, name string, data []byte) error {
+	tmp, err := writeTemp(filepath.Join(dir, filepath.Dir(name)), data)
+	if err != nil {
+		return err
+	}
+	j.Renames = append(j.Renames, rename{From: filepath.Join(filepath.Dir(name), filepath.Base(tmp)), To: name})
+	return nil
+}
+
+// discard удаляет временные файлы журнала, который так и не был записан.
+func (j *journal) discard(dir string) {
+	for _, r := range j.Renames {
+		os.Remove(filepath.Join(dir, r.From))
+	}
+}
+
+// apply переносит изменения на место и удаляет журнал. Повторять apply
+// после сбоя безопасно: уже перенесённые файлы пропускаются, а история
+// обрезается до HistoryOffset и дописывается заново.
+func (j *journal) apply(dir string) error {
+	for _, r := range j.Renames {
+		err := os.Rename(filepath.Join(dir, r.From), filepath.Join(dir, r.To))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, name := range j.Removes {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if j.History != "" {
+		f, err := os.OpenFile(filepath.Join(dir, metaDir, historyFile), os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		err = f.Truncate(j.HistoryOffset)
+		if err == nil {
+			_, err = f.WriteAt([]byte(j.History), j.HistoryOffset)
+		}
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(filepath.Join(dir, metaDir, journalFile))
+}
+
+func (b *backend) writeJournal(j *journal) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(b.dir, metaDir, journalFile), data)
+}
+
+// replayJournal доводит до конца транзакцию, журнал которой остался после сбоя.
+func (b *backend) replayJournal() error {
+	data, err := os.ReadFile(filepath.Join(b.dir, metaDir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var j journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return fmt.Errorf("error reading journal: %w", err)
+	}
+	log.Printf("filestore: completing an interrupted transaction")
+	return j.apply(b.dir)
+}
@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+package filestore
+
+import "os"
+
+// На остальных платформах блокировки файлов нет, и каталог может
+// использовать только один процесс.
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
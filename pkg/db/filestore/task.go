@@ -0,0 +1,98 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter — поля задачи в заголовке файла. Комментарий хранится в теле.
+type frontMatter struct {
+	Title   string `yaml:"title"`
+	Date    string `yaml:"date"`
+	Repeat  string `yaml:"repeat"`
+	Status  string `yaml:"status"`
+	Version int64  `yaml:"version"`
+	Anchor  string `yaml:"anchor,omitempty"`
+}
+
+const delimiter = "---\n"
+
+func encodeTask(task *db.Task) []byte {
+	fm, _ := yaml.Marshal(frontMatter{
+		Title:   task.Title,
+		Date:    task.Date,
+		Repeat:  task.Repeat,
+		Status:  task.Status,
+		Version: task.Version,
+		Anchor:  task.Anchor,
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(delimiter)
+	buf.Write(fm)
+	buf.WriteString(delimiter)
+	if task.Comment != "" {
+		buf.WriteString(task.Comment)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// readTask читает файл задачи. Задача без версии считается новой.
+func readTask(path string, id string) (*db.Task, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	rest, ok := strings.CutPrefix(text, delimiter)
+	if !ok {
+		return nil, errors.New("missing front matter")
+	}
+	header, body, ok := strings.Cut(rest, "\n"+delimiter)
+	if !ok {
+		if header, ok = strings.CutSuffix(rest, "\n---"); !ok {
+			return nil, errors.New("unterminated front matter")
+		}
+	}
+
+	var fm frontMatter
+	if err := yaml.Unmarshal([]byte(header), &fm); err != nil {
+		return nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	if fm.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	if _, err := time.Parse("20060102", fm.Date); err != nil {
+		return nil, fmt.Errorf("invalid date %q", fm.Date)
+	}
+	if _, err := time.Parse("20060102", fm.Anchor); fm.Anchor != "" && err != nil {
+		return nil, fmt.Errorf("invalid anchor %q", fm.Anchor)
+	}
+	if fm.Status == "" {
+		fm.Status = db.StatusTodo
+	}
+	if !db.ValidStatus(fm.Status) {
+		return nil, fmt.Errorf("unknown status %q", fm.Status)
+	}
+
+	return &db.Task{
+		ID:      id,
+		Date:    fm.Date,
+		Title:   fm.Title,
+		Comment: strings.TrimSuffix(body, "\n"),
+		Repeat:  fm.Repeat,
+		Status:  fm.Status,
+		Version: fm.Version,
+		Anchor:  fm.Anchor,
+	}, nil
+}
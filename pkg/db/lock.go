@@ -0,0 +1,33 @@
+package db
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrLocked возвращается Lock, если файлом базы уже пользуется другой процесс.
+var ErrLocked = errors.New("database is in use by another process")
+
+// Lock берёт исключительную блокировку файла SQLite dsn на всё время работы
+// с ним: сервер держит её, пока запущен, а восстановление из снимка — пока
+// подменяет файл. Блокировка хранится в файле dsn+".lock". Если её держит
+// другой процесс, Lock сразу возвращает ErrLocked. Для PostgreSQL
+// блокировка не нужна. Возвращённая функция снимает блокировку.
+func Lock(dsn string) (func() error, error) {
+	if isPostgresDSN(dsn) {
+		return func() error { return nil }, nil
+	}
+
+	f, err := os.OpenFile(dsn+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := tryLockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() error {
+		unlockFile(f)
+		return f.Close()
+	}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package db
+
+import "os"
+
+// На остальных платформах блокировки файлов нет, и Lock ничего не проверяет.
+
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,837 @@
+// Package memstore реализует db.Store в памяти. Он нужен для быстрых тестов
+// обработчиков API, которым не нужен файл базы, а с Backend служит основой
+// для хранилищ, которые держат данные в памяти и сохраняют их сами
+// (см. pkg/db/filestore).
+package memstore
+
+import (
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State — содержимое хранилища. Задачи меняются только через копии,
+// а ревизии и шаблоны после добавления не меняются вовсе.
+type State struct {
+	Tasks        map[string]*db.Task
+	Revisions    []*db.Revision
+	Templates    map[string]*db.Template
+	Feeds        map[string]*db.Feed
+	Refs         map[string]*db.ExternalRef
+	Searches     map[string]*db.SavedSearch
+	LastID       int64
+	LastRevID    int64
+	LastTplID    int64
+	LastFeedID   int64
+	LastSearchID int64
+}
+
+// NewState возвращает пустое состояние.
+func NewState() *State {
+	return &State{
+		Tasks:     make(map[string]*db.Task),
+		Templates: make(map[string]*db.Template),
+		Feeds:     make(map[string]*db.Feed),
+		Refs:      make(map[string]*db.ExternalRef),
+		Searches:  make(map[string]*db.SavedSearch),
+	}
+}
+
+// Backend сохраняет состояние хранилища вне памяти.
+type Backend interface {
+	// Acquire вызывается перед каждой операцией и транзакцией. Он берёт
+	// блокировку для других процессов и может обновить st, если данные
+	// изменились в обход хранилища.
+	Acquire(st *State) error
+	// Save сохраняет изменения операции или транзакции. Если он вернул
+	// ошибку, изменения в памяти отменяются.
+	Save(before, after *State) error
+	// Release снимает блокировку, взятую в Acquire.
+	Release()
+}
+
+func (s *State) clone() *State {
+	c := *s
+	c.Tasks = make(map[string]*db.Task, len(s.Tasks))
+	for id, task := range s.Tasks {
+		t := *task
+		c.Tasks[id] = &t
+	}
+	// Ревизии, шаблоны, ленты и внешние ссылки после добавления не меняются,
+	// а сохранённый поиск при изменении заменяется новым, поэтому достаточно
+	// скопировать указатели.
+	c.Revisions = append([]*db.Revision(nil), s.Revisions...)
+	c.Templates = make(map[string]*db.Template, len(s.Templates))
+	for id, tpl := range s.Templates {
+		c.Templates[id] = tpl
+	}
+	c.Feeds = make(map[string]*db.Feed, len(s.Feeds))
+	for id, feed := range s.Feeds {
+		c.Feeds[id] = feed
+	}
+	c.Refs = make(map[string]*db.ExternalRef, len(s.Refs))
+	for key, ref := range s.Refs {
+		c.Refs[key] = ref
+	}
+	c.Searches = make(map[string]*db.SavedSearch, len(s.Searches))
+	for id, search := range s.Searches {
+		c.Searches[id] = search
+	}
+	return &c
+}
+
+type shared struct {
+	mu      sync.Mutex
+	st      *State
+	backend Backend
+}
+
+// lock берёт блокировку хранилища и, если есть Backend, его блокировку.
+func (sh *shared) lock() error {
+	sh.mu.Lock()
+	if sh.backend == nil {
+		return nil
+	}
+	if err := sh.backend.Acquire(sh.st); err != nil {
+		sh.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (sh *shared) unlock() {
+	if sh.backend != nil {
+		sh.backend.Release()
+	}
+	sh.mu.Unlock()
+}
+
+// save сохраняет изменения через Backend, а при ошибке возвращает состояние before.
+func (sh *shared) save(before *State) error {
+	if sh.backend == nil {
+		return nil
+	}
+	if err := sh.backend.Save(before, sh.st); err != nil {
+		sh.st = before
+		return err
+	}
+	return nil
+}
+
+type savepoint struct {
+	name string
+	st   *State
+}
+
+type txState struct {
+	begin      *State
+	savepoints []savepoint
+}
+
+// Store — хранилище задач в памяти. Транзакция держит блокировку
+// до Commit или Rollback, поэтому транзакции выполняются по очереди.
+type Store struct {
+	sh    *shared
+	tx    *txState
+	actor string
+}
+
+var _ db.Tx = (*Store)(nil)
+
+func New() *Store {
+	return &Store{sh: &shared{st: NewState()}}
+}
+
+// NewWithBackend возвращает хранилище, которое загружает и сохраняет
+// данные через backend.
+func NewWithBackend(backend Backend) (*Store, error) {
+	sh := &shared{st: NewState(), backend: backend}
+	if err := sh.lock(); err != nil {
+		return nil, err
+	}
+	sh.unlock()
+	return &Store{sh: sh}, nil
+}
+
+func (s *Store) WithActor(actor string) db.Store {
+	c := *s
+	c.actor = actor
+	return &c
+}
+
+func (s *Store) Begin() (db.Tx, error) {
+	if s.tx != nil {
+		return nil, errors.New("transaction already started")
+	}
+	if err := s.sh.lock(); err != nil {
+		return nil, err
+	}
+	return &Store{sh: s.sh, tx: &txState{begin: s.sh.st.clone()}, actor: s.actor}, nil
+}
+
+func (s *Store) Commit() error {
+	defer s.sh.unlock()
+	return s.sh.save(s.tx.begin)
+}
+
+func (s *Store) Rollback() error {
+	s.sh.st = s.tx.begin
+	s.sh.unlock()
+	return nil
+}
+
+func (s *Store) InTx(fn func(tx db.Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) Savepoint(name string) error {
+	s.tx.savepoints = append(s.tx.savepoints, savepoint{name: name, st: s.sh.st.clone()})
+	return nil
+}
+
+func (s *Store) RollbackTo(name string) error {
+	for i := len(s.tx.savepoints) - 1; i >= 0; i-- {
+		if sp := s.tx.savepoints[i]; sp.name == name {
+			s.sh.st = sp.st.clone()
+			s.tx.savepoints = s.tx.savepoints[:i+1]
+			return nil
+		}
+	}
+	return fmt.Errorf("no such savepoint: %s", name)
+}
+
+func (s *Store) Release(name string) error {
+	for i := len(s.tx.savepoints) - 1; i >= 0; i-- {
+		if s.tx.savepoints[i].name == name {
+			s.tx.savepoints = s.tx.savepoints[:i]
+			return nil
+		}
+	}
+	return fmt.Errorf("no such savepoint: %s", name)
+}
+
+// read выполняет fn под блокировкой, если хранилище не в транзакции.
+func (s *Store) read(fn func(st *State) error) error {
+	if s.tx == nil {
+		if err := s.sh.lock(); err != nil {
+			return err
+		}
+		defer s.sh.unlock()
+	}
+	return fn(s.sh.st)
+}
+
+// write выполняет изменение fn атомарно: при ошибке состояние восстанавливается.
+func (s *Store) write(fn func(st *State) error) error {
+	if s.tx == nil {
+		if err := s.sh.lock(); err != nil {
+			return err
+		}
+		defer s.sh.unlock()
+	}
+	backup := s.sh.st.clone()
+	if err := fn(s.sh.st); err != nil {
+		s.sh.st = backup
+		return err
+	}
+	if s.tx == nil {
+		return s.sh.save(backup)
+	}
+	return nil
+}
+
+func (s *Store) Tasks(limit int, filter db.TaskFilter) (tasks []*db.Task, err error) {
+	query, err := db.ParseQuery(filter.Search)
+	if err != nil {
+		return nil, err
+	}
+	err = s.read(func(st *State) error {
+		tasks = make([]*db.Task, 0)
+		for _, task := range st.Tasks {
+			if matches(task, filter, query) {
+				t := *task
+				tasks = append(tasks, &t)
+			}
+		}
+		return nil
+	})
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Date != tasks[j].Date {
+			return tasks[i].Date < tasks[j].Date
+		}
+		return taskID(tasks[i]) < taskID(tasks[j])
+	})
+	terms := query.Terms()
+	for _, task := range tasks {
+		task.Snippet = db.Snippet(task, terms)
+	}
+	switch filter.Order() {
+	case db.SortRelevance:
+		db.SortByRank(tasks, terms)
+	case db.SortTitle:
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return strings.ToLower(tasks[i].Title) < strings.ToLower(tasks[j].Title)
+		})
+	}
+	if after := filter.After; after != nil {
+		tasks = tasksAfter(tasks, after, filter.Order() != db.SortDate)
+	}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+	return tasks, err
+}
+
+// tasksAfter отбрасывает задачи, выданные на предыдущих страницах.
+func tasksAfter(tasks []*db.Task, after *db.Cursor, byOffset bool) []*db.Task {
+	if byOffset {
+		return tasks[min(after.Offset, len(tasks)):]
+	}
+	i := sort.Search(len(tasks), func(i int) bool {
+		return tasks[i].Date > after.Date || tasks[i].Date == after.Date && taskID(tasks[i]) > after.ID
+	})
+	return tasks[i:]
+}
+
+func (s *Store) EachTask(fn func(task *db.Task) error) error {
+	tasks, err := s.Tasks(math.MaxInt, db.TaskFilter{})
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matches(task *db.Task, filter db.TaskFilter, query *db.Query) bool {
+	if len(filter.Statuses) > 0 {
+		found := false
+		for _, status := range filter.Statuses {
+			found = found || task.Status == status
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !filter.InRange(task) {
+		return false
+	}
+	return query.Match(task)
+}
+
+func taskID(task *db.Task) int64 {
+	id, _ := strconv.ParseInt(task.ID, 10, 64)
+	return id
+}
+
+func (s *Store) GetTask(id string) (task *db.Task, err error) {
+	err = s.read(func(st *State) error {
+		t, ok := st.Tasks[id]
+		if !ok {
+			return db.ErrNotFound
+		}
+		copied := *t
+		task = &copied
+		return nil
+	})
+	return task, err
+}
+
+func (s *Store) AddTask(task *db.Task) (id int64, err error) {
+	err = s.write(func(st *State) error {
+		id, err = s.add(st, task)
+		return err
+	})
+	return id, err
+}
+
+func (s *Store) AddTasks(tasks []*db.Task) (ids []int64, err error) {
+	err = s.write(func(st *State) error {
+		ids = make([]int64, 0, len(tasks))
+		for _, task := range tasks {
+			id, err := s.add(st, task)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (s *Store) add(st *State, task *db.Task) (int64, error) {
+	if task.Title == "" || len(task.Date) != 8 {
+		return 0, errors.New("invalid task")
+	}
+	if task.Status == "" {
+		task.Status = db.StatusTodo
+	}
+	task.Version, task.Anchor = 1, ""
+
+	st.LastID++
+	stored := *task
+	stored.ID = strconv.FormatInt(st.LastID, 10)
+	st.Tasks[stored.ID] = &stored
+	s.record(st, db.ActionInsert, stored.ID, nil, &stored)
+	return st.LastID, nil
+}
+
+func (s *Store) UpdateTask(task *db.Task) error {
+	after, err := s.change(db.ActionUpdate, task.ID, task.Version, func(t *db.Task) error {
+		t.Anchor = t.EditedAnchor(task)
+		t.Date, t.Title, t.Comment, t.Repeat = task.Date, task.Title, task.Comment, task.Repeat
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	task.Version, task.Anchor = after.Version, after.Anchor
+	return nil
+}
+
+// RestoreTask записывает задачу целиком. Если задачи нет, а версия
+// не задана, задача создаётся с идентификатором task.ID, как в RevertTask.
+func (s *Store) RestoreTask(task *db.Task) error {
+	return s.write(func(st *State) error {
+		current, ok := st.Tasks[task.ID]
+		if !ok && task.Version != 0 {
+			return db.ErrNotFound
+		}
+		if ok && task.Version != 0 && task.Version != current.Version {
+			return db.ErrConflict
+		}
+
+		restored := *task
+		restored.Snippet = ""
+		var before *db.Task
+		action := db.ActionInsert
+		if ok {
+			copied := *current
+			before = &copied
+			restored.Version = current.Version + 1
+			action = db.ActionUpdate
+		} else {
+			id, err := strconv.ParseInt(task.ID, 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid task id %q", task.ID)
+			}
+			st.LastID = max(st.LastID, id)
+			restored.Version = lastVersion(st, task.ID) + 1
+		}
+		st.Tasks[task.ID] = &restored
+		s.record(st, action, task.ID, before, &restored)
+		task.Version = restored.Version
+		return nil
+	})
+}
+
+func (s *Store) DeleteTask(id string, version int64) error {
+	_, err := s.change(db.ActionDelete, id, version, nil)
+	return err
+}
+
+func (s *Store) UpdateDate(next string, id string) error {
+	_, err := s.change(db.ActionDate, id, 0, func(t *db.Task) error {
+		t.Date, t.Anchor = next, t.SnoozedAnchor(next)
+		return nil
+	})
+	return err
+}
+
+func (s *Store) UpdateStatus(id string, status string) error {
+	_, err := s.change(db.ActionStatus, id, 0, func(t *db.Task) error {
+		if !db.CanTransition(t.Status, status) {
+			return &db.TransitionError{From: t.Status, To: status}
+		}
+		t.Status = status
+		return nil
+	})
+	return err
+}
+
+func (s *Store) CompleteTask(id string, version int64, next func(task *db.Task) (string, error)) error {
+	_, err := s.change(db.ActionDone, id, version, func(t *db.Task) error {
+		if !db.CanTransition(t.Status, db.StatusDone) {
+			return &db.TransitionError{From: t.Status, To: db.StatusDone}
+		}
+		date, err := next(t)
+		if err != nil {
+			return err
+		}
+		if date == "" {
+			t.Status = db.StatusDone
+		} else {
+			t.Date, t.Status, t.Anchor = date, db.StatusTodo, ""
+		}
+		return nil
+	})
+	return err
+}
+
+// change изменяет копию задачи через fn и сохраняет её с новой версией.
+// Без fn задача удаляется.
+func (s *Store) change(action string, id string, version int64, fn func(t *db.Task) error) (after *db.Task, err error) {
+	err = s.write(func(st *State) error {
+		current, ok := st.Tasks[id]
+		if !ok {
+			return db.ErrNotFound
+		}
+		if version != 0 && version != current.Version {
+			return db.ErrConflict
+		}
+		before := *current
+
+		if fn == nil {
+			delete(st.Tasks, id)
+			s.record(st, action, id, &before, nil)
+			return nil
+		}
+
+		changed := before
+		if err := fn(&changed); err != nil {
+			return err
+		}
+		changed.Version++
+		st.Tasks[id] = &changed
+		s.record(st, action, id, &before, &changed)
+		copied := changed
+		after = &copied
+		return nil
+	})
+	return after, err
+}
+
+func (s *Store) History(taskID string) (revisions []*db.Revision, err error) {
+	err = s.read(func(st *State) error {
+		revisions = make([]*db.Revision, 0)
+		for _, rev := range st.Revisions {
+			if rev.TaskID == taskID {
+				revisions = append(revisions, rev)
+			}
+		}
+		return nil
+	})
+	return revisions, err
+}
+
+// Completions, как и Repository.Completions, сравнивает created_at со
+// временем начала дней from и следующего за to.
+func (s *Store) Completions(from, to string) (revisions []*db.Revision, err error) {
+	start, err := time.Parse("20060102", from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse("20060102", to)
+	if err != nil {
+		return nil, err
+	}
+	first, last := start.Format(time.RFC3339), end.AddDate(0, 0, 1).Format(time.RFC3339)
+	err = s.read(func(st *State) error {
+		revisions = make([]*db.Revision, 0)
+		for _, rev := range st.Revisions {
+			if rev.Action == db.ActionDone && rev.CreatedAt >= first && rev.CreatedAt < last {
+				revisions = append(revisions, rev)
+			}
+		}
+		return nil
+	})
+	return revisions, err
+}
+
+func (s *Store) LastRevisionID() (id int64, err error) {
+	err = s.read(func(st *State) error {
+		id = st.LastRevID
+		return nil
+	})
+	return id, err
+}
+
+func (s *Store) RevertTask(id string, revisionID string) (after *db.Task, err error) {
+	err = s.write(func(st *State) error {
+		var rev *db.Revision
+		for _, r := range st.Revisions {
+			if r.ID == revisionID && r.TaskID == id {
+				rev = r
+			}
+		}
+		if rev == nil {
+			return db.ErrRevisionNotFound
+		}
+
+		var snapshot db.Task
+		if rev.After != nil {
+			snapshot = *rev.After
+		} else {
+			snapshot = *rev.Before
+		}
+
+		var before *db.Task
+		if current, ok := st.Tasks[id]; ok {
+			copied := *current
+			before = &copied
+			snapshot.Version = current.Version + 1
+		} else {
+			snapshot.Version = lastVersion(st, id) + 1
+		}
+		st.Tasks[id] = &snapshot
+		copied := snapshot
+		after = &copied
+		s.record(st, db.ActionRevert, id, before, &snapshot)
+		return nil
+	})
+	return after, err
+}
+
+func lastVersion(st *State, id string) int64 {
+	for i := len(st.Revisions) - 1; i >= 0; i-- {
+		rev := st.Revisions[i]
+		if rev.TaskID != id {
+			continue
+		}
+		if rev.After != nil {
+			return rev.After.Version
+		}
+		return rev.Before.Version
+	}
+	return 0
+}
+
+func (s *Store) record(st *State, action string, id string, before, after *db.Task) {
+	st.LastRevID++
+	rev := &db.Revision{
+		ID:        strconv.FormatInt(st.LastRevID, 10),
+		TaskID:    id,
+		Action:    action,
+		Actor:     s.actor,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if before != nil {
+		t := *before
+		rev.Before = &t
+	}
+	if after != nil {
+		t := *after
+		rev.After = &t
+	}
+	st.Revisions = append(st.Revisions, rev)
+}
+
+func (s *Store) Templates() (templates []*db.Template, err error) {
+	err = s.read(func(st *State) error {
+		templates = make([]*db.Template, 0, len(st.Templates))
+		for _, tpl := range st.Templates {
+			templates = append(templates, tpl)
+		}
+		return nil
+	})
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, err
+}
+
+func (s *Store) GetTemplate(id string) (tpl *db.Template, err error) {
+	err = s.read(func(st *State) error {
+		var ok bool
+		if tpl, ok = st.Templates[id]; !ok {
+			return errors.New("template not found")
+		}
+		return nil
+	})
+	return tpl, err
+}
+
+func (s *Store) AddTemplate(tpl *db.Template) (id int64, err error) {
+	err = s.write(func(st *State) error {
+		st.LastTplID++
+		id = st.LastTplID
+		stored := &db.Template{ID: strconv.FormatInt(id, 10), Name: tpl.Name}
+		for _, t := range tpl.Tasks {
+			copied := *t
+			stored.Tasks = append(stored.Tasks, &copied)
+		}
+		st.Templates[stored.ID] = stored
+		return nil
+	})
+	return id, err
+}
+
+func (s *Store) DeleteTemplate(id string) error {
+	return s.write(func(st *State) error {
+		if _, ok := st.Templates[id]; !ok {
+			return errors.New("template not found")
+		}
+		delete(st.Templates, id)
+		return nil
+	})
+}
+
+func (s *Store) Feeds() (feeds []*db.Feed, err error) {
+	err = s.read(func(st *State) error {
+		feeds = make([]*db.Feed, 0, len(st.Feeds))
+		for _, feed := range st.Feeds {
+			feeds = append(feeds, feed)
+		}
+		return nil
+	})
+	sort.Slice(feeds, func(i, j int) bool {
+		return feedID(feeds[i]) < feedID(feeds[j])
+	})
+	return feeds, err
+}
+
+func feedID(feed *db.Feed) int64 {
+	id, _ := strconv.ParseInt(feed.ID, 10, 64)
+	return id
+}
+
+func (s *Store) FeedByToken(tokenHash string) (feed *db.Feed, err error) {
+	err = s.read(func(st *State) error {
+		for _, f := range st.Feeds {
+			if f.TokenHash == tokenHash {
+				feed = f
+				return nil
+			}
+		}
+		return db.ErrFeedNotFound
+	})
+	return feed, err
+}
+
+func (s *Store) AddFeed(feed *db.Feed) (id int64, err error) {
+	err = s.write(func(st *State) error {
+		st.LastFeedID++
+		id = st.LastFeedID
+		stored := *feed
+		stored.ID = strconv.FormatInt(id, 10)
+		stored.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		st.Feeds[stored.ID] = &stored
+		feed.CreatedAt = stored.CreatedAt
+		return nil
+	})
+	return id, err
+}
+
+func (s *Store) DeleteFeed(id string) error {
+	return s.write(func(st *State) error {
+		if _, ok := st.Feeds[id]; !ok {
+			return db.ErrFeedNotFound
+		}
+		delete(st.Feeds, id)
+		return nil
+	})
+}
+
+func (s *Store) SavedSearches() (searches []*db.SavedSearch, err error) {
+	err = s.read(func(st *State) error {
+		searches = make([]*db.SavedSearch, 0, len(st.Searches))
+		for _, search := range st.Searches {
+			searches = append(searches, search)
+		}
+		return nil
+	})
+	sort.Slice(searches, func(i, j int) bool {
+		return searchID(searches[i]) < searchID(searches[j])
+	})
+	return searches, err
+}
+
+func searchID(search *db.SavedSearch) int64 {
+	id, _ := strconv.ParseInt(search.ID, 10, 64)
+	return id
+}
+
+func (s *Store) GetSavedSearch(id string) (search *db.SavedSearch, err error) {
+	err = s.read(func(st *State) error {
+		stored, ok := st.Searches[id]
+		if !ok {
+			return db.ErrSavedSearchNotFound
+		}
+		c := *stored
+		search = &c
+		return nil
+	})
+	return search, err
+}
+
+func (s *Store) AddSavedSearch(search *db.SavedSearch) (id int64, err error) {
+	err = s.write(func(st *State) error {
+		st.LastSearchID++
+		id = st.LastSearchID
+		stored := *search
+		stored.ID = strconv.FormatInt(id, 10)
+		st.Searches[stored.ID] = &stored
+		return nil
+	})
+	return id, err
+}
+
+func (s *Store) UpdateSavedSearch(search *db.SavedSearch) error {
+	return s.write(func(st *State) error {
+		if _, ok := st.Searches[search.ID]; !ok {
+			return db.ErrSavedSearchNotFound
+		}
+		stored := *search
+		st.Searches[stored.ID] = &stored
+		return nil
+	})
+}
+
+func (s *Store) DeleteSavedSearch(id string) error {
+	return s.write(func(st *State) error {
+		if _, ok := st.Searches[id]; !ok {
+			return db.ErrSavedSearchNotFound
+		}
+		delete(st.Searches, id)
+		return nil
+	})
+}
+
+// RefKey — ключ внешней ссылки в State.Refs.
+func RefKey(source, ref string) string {
+	return source + "\x00" + ref
+}
+
+func (s *Store) ExternalRef(source, ref string) (taskID string, err error) {
+	err = s.read(func(st *State) error {
+		if r, ok := st.Refs[RefKey(source, ref)]; ok {
+			taskID = r.TaskID
+		}
+		return nil
+	})
+	return taskID, err
+}
+
+func (s *Store) AddExternalRef(ref *db.ExternalRef) error {
+	return s.write(func(st *State) error {
+		key := RefKey(ref.Source, ref.Ref)
+		if _, ok := st.Refs[key]; ok {
+			return fmt.Errorf("external reference %s %q already exists", ref.Source, ref.Ref)
+		}
+		stored := *ref
+		st.Refs[key] = &stored
+		return nil
+	})
+}
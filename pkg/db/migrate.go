@@ -0,0 +1,294 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migration — шаг изменения схемы. Шаги выполняются по возрастанию номера,
+// и каждый применяется к базе ровно один раз.
+//
+// Базы, созданные до появления миграций, не содержат schema_version, хотя
+// часть изменений в них уже может быть. Поэтому шаги написаны так, чтобы
+// повторное создание таблицы, индекса или колонки ничего не ломало.
+type migration struct {
+	version int
+	name    string
+	up      func(q querier) error
+}
+
+var migrations = []migration{
+	{1, "create scheduler", execAll(
+		`CREATE TABLE IF NOT EXISTS scheduler (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date CHAR(8) NOT NULL CHECK(length(date) = 8),
+    title VARCHAR(255) NOT NULL CHECK(title != ''),
+    comment TEXT NOT NULL DEFAULT '',
+    repeat VARCHAR(128) NOT NULL DEFAULT ''
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date)`,
+	)},
+	{2, "add task status", func(q querier) error {
+		if err := addColumn(q, "scheduler", "status", `VARCHAR(16) NOT NULL DEFAULT 'todo'`); err != nil {
+			return err
+		}
+		_, err := q.Exec(`CREATE INDEX IF NOT EXISTS idx_status ON scheduler(status)`)
+		return err
+	}},
+	{3, "create templates", execAll(
+		`CREATE TABLE IF NOT EXISTS template (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL CHECK(name != '')
+)`,
+		`CREATE TABLE IF NOT EXISTS template_task (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    template_id INTEGER NOT NULL,
+    day_offset INTEGER NOT NULL DEFAULT 0 CHECK(day_offset >= 0),
+    title VARCHAR(255) NOT NULL CHECK(title != ''),
+    comment TEXT NOT NULL DEFAULT '',
+    repeat VARCHAR(128) NOT NULL DEFAULT ''
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_template_task ON template_task(template_id)`,
+	)},
+	{4, "create revision history", execAll(
+		`CREATE TABLE IF NOT EXISTS revision (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    before TEXT NOT NULL DEFAULT '',
+    after TEXT NOT NULL DEFAULT '',
+    created_at VARCHAR(32) NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_revision_task ON revision(task_id)`,
+	)},
+	{5, "add task version", func(q querier) error {
+		return addColumn(q, "scheduler", "version", `INTEGER NOT NULL DEFAULT 1`)
+	}},
+	{6, "create calendar feeds", execAll(
+		`CREATE TABLE IF NOT EXISTS feed (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL CHECK(name != ''),
+    kind VARCHAR(16) NOT NULL DEFAULT 'event',
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at VARCHAR(32) NOT NULL
+)`,
+	)},
+	{7, "create external references", execAll(
+		`CREATE TABLE IF NOT EXISTS external_ref (
+    source VARCHAR(32) NOT NULL,
+    ref VARCHAR(512) NOT NULL,
+    task_id INTEGER NOT NULL,
+    PRIMARY KEY (source, ref)
+)`,
+	)},
+	{8, "create full-text search index", func(q querier) error {
+		if _, ok := q.(pgQuerier); ok {
+			_, err := q.Exec(`CREATE INDEX IF NOT EXISTS idx_scheduler_fts ON scheduler
+USING GIN (to_tsvector('simple', title || ' ' || comment))`)
+			return err
+		}
+		// Индекс хранит только слова, сами тексты читаются из scheduler.
+		// unicode61 приводит к нижнему регистру и кириллицу, чего не умеет LIKE.
+		return execAll(
+			`CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(
+    title, comment, content='scheduler', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+)`,
+			`CREATE TRIGGER IF NOT EXISTS scheduler_fts_insert AFTER INSERT ON scheduler BEGIN
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END`,
+			`CREATE TRIGGER IF NOT EXISTS scheduler_fts_delete AFTER DELETE ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+END`,
+			`CREATE TRIGGER IF NOT EXISTS scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END`,
+			// Заполняет индекс задачами, созданными до миграции.
+			`INSERT INTO scheduler_fts(scheduler_fts) VALUES ('rebuild')`,
+		)(q)
+	}},
+	{9, "create saved searches", execAll(
+		`CREATE TABLE IF NOT EXISTS saved_search (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL CHECK(name != ''),
+    query TEXT NOT NULL DEFAULT '',
+    sort VARCHAR(16) NOT NULL DEFAULT ''
+)`,
+	)},
+	{10, "index completions", execAll(
+		`CREATE INDEX IF NOT EXISTS idx_revision_action ON revision(action, created_at)`,
+	)},
+	{11, "add task anchor", func(q querier) error {
+		return addColumn(q, "scheduler", "anchor", `VARCHAR(8) NOT NULL DEFAULT ''`)
+	}},
+}
+
+// MigrationStatus описывает шаг миграции и то, применён ли он к базе.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt string
+}
+
+func (m MigrationStatus) Applied() bool {
+	return m.AppliedAt != ""
+}
+
+// SchemaVersion возвращает номер последней применённой миграции.
+func SchemaVersion(db *sql.DB) (int, error) {
+	applied, err := appliedMigrations(wrap(db, dialectOf(db)))
+	if err != nil {
+		return 0, err
+	}
+	return currentVersion(applied), nil
+}
+
+// LatestSchemaVersion возвращает номер последней известной миграции.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrations возвращает все известные шаги миграции с отметкой о применении.
+func Migrations(db *sql.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(wrap(db, dialectOf(db)))
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		list = append(list, MigrationStatus{Version: m.version, Name: m.name, AppliedAt: applied[m.version]})
+	}
+	return list, nil
+}
+
+// Migrate применяет недостающие миграции в одной транзакции и возвращает
+// их список. При dryRun миграции выполняются, но транзакция откатывается,
+// так что можно проверить, что они пройдут, не меняя базу.
+func Migrate(db *sql.DB, dryRun bool) ([]MigrationStatus, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := wrap(tx, dialectOf(db))
+
+	_, err = q.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at VARCHAR(32) NOT NULL
+)`)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := appliedMigrations(q)
+	if err != nil {
+		return nil, err
+	}
+	current := currentVersion(done)
+	if current > LatestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than supported %d", current, LatestSchemaVersion())
+	}
+
+	var applied []MigrationStatus
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := m.up(q); err != nil {
+			return nil, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		at := time.Now().UTC().Format(time.RFC3339)
+		query := `INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`
+		if _, err := q.Exec(query, m.version, m.name, at); err != nil {
+			return nil, err
+		}
+		applied = append(applied, MigrationStatus{Version: m.version, Name: m.name, AppliedAt: at})
+	}
+
+	if dryRun {
+		return applied, nil
+	}
+	return applied, tx.Commit()
+}
+
+// appliedMigrations возвращает время применения каждой выполненной миграции.
+// База без таблицы schema_version считается не мигрированной.
+func appliedMigrations(q querier) (map[int]string, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`
+	if _, ok := q.(pgQuerier); ok {
+		query = `SELECT COUNT(*) FROM information_schema.tables
+WHERE table_schema = current_schema() AND table_name = 'schema_version'`
+	}
+	if err := q.QueryRow(query).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]string)
+	if count == 0 {
+		return applied, nil
+	}
+
+	rows, err := q.Query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func currentVersion(applied map[int]string) int {
+	current := 0
+	for version := range applied {
+		current = max(current, version)
+	}
+	return current
+}
+
+// execAll выполняет запросы по очереди. Схема описана для SQLite; в PostgreSQL
+// автоинкрементный ключ заменяется на SERIAL.
+func execAll(queries ...string) func(q querier) error {
+	return func(q querier) error {
+		for _, query := range queries {
+			if _, ok := q.(pgQuerier); ok {
+				query = strings.ReplaceAll(query, `INTEGER PRIMARY KEY AUTOINCREMENT`, `SERIAL PRIMARY KEY`)
+			}
+			if _, err := q.Exec(query); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumn добавляет колонку, если её ещё нет.
+func addColumn(q querier, table, column, definition string) error {
+	if _, ok := q.(pgQuerier); ok {
+		_, err := q.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s`, table, column, definition))
+		return err
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	if err := q.QueryRow(query, table, column).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := q.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
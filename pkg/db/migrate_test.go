@@ -0,0 +1,90 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Схемы, которые встречаются в базах, созданных до появления миграций.
+const (
+	baselineSchema = `
+CREATE TABLE scheduler (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date CHAR(8) NOT NULL CHECK(length(date) = 8),
+    title VARCHAR(255) NOT NULL CHECK(title != ''),
+    comment TEXT NOT NULL DEFAULT '',
+    repeat VARCHAR(128) NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_date ON scheduler(date);
+INSERT INTO scheduler (date, title, repeat) VALUES ('20240101', 'Старая задача', 'd 1');
+`
+	statusSchema = baselineSchema + `
+ALTER TABLE scheduler ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'todo';
+CREATE INDEX idx_status ON scheduler(status);
+`
+)
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	for name, schema := range map[string]string{
+		"empty":    "",
+		"baseline": baselineSchema,
+		"status":   statusSchema,
+	} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "scheduler.db")
+			if schema != "" {
+				raw, err := Connect(file)
+				require.NoError(t, err)
+				_, err = raw.Exec(schema)
+				require.NoError(t, err)
+				raw.Close()
+			}
+
+			db, err := Open(file)
+			require.NoError(t, err)
+			defer db.Close()
+
+			version, err := SchemaVersion(db)
+			require.NoError(t, err)
+			assert.Equal(t, LatestSchemaVersion(), version)
+
+			repo := NewRepository(db)
+			id, err := repo.AddTask(&Task{Date: "20240102", Title: "Новая задача"})
+			require.NoError(t, err)
+			require.NoError(t, repo.CompleteTask("1", 0, func(*Task) (string, error) { return "", nil }))
+
+			tasks, err := repo.Tasks(10, TaskFilter{})
+			require.NoError(t, err)
+			assert.Len(t, tasks, int(id))
+
+			// Задачи, созданные до миграции, попадают в полнотекстовый индекс.
+			tasks, err = repo.Tasks(10, TaskFilter{Search: "задача"})
+			require.NoError(t, err)
+			assert.Len(t, tasks, int(id))
+
+			applied, err := Migrate(db, false)
+			require.NoError(t, err)
+			assert.Empty(t, applied)
+		})
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scheduler.db")
+	db, err := Connect(file)
+	require.NoError(t, err)
+	defer db.Close()
+
+	applied, err := Migrate(db, true)
+	require.NoError(t, err)
+	assert.Len(t, applied, LatestSchemaVersion())
+
+	list, err := Migrations(db)
+	require.NoError(t, err)
+	for _, m := range list {
+		assert.False(t, m.Applied(), m.Name)
+	}
+}
@@ -0,0 +1,354 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go1f/pkg/dates"
+)
+
+// Query — разобранная строка поиска. Задача подходит, если выполнены все
+// условия. Строка состоит из слов, разделённых пробелами:
+//
+//	купить            слово, с которого начинается слово задачи
+//	"точная фраза"    слова подряд
+//	tag:работа        метка +работа или @работа в названии
+//	repeat:any        повторяющиеся задачи; none — разовые, d, w, m, y — вид правила
+//	before:01.12.2026 дата раньше указанной; after — позже, on — в этот день
+//	01.12.2026        то же, что on:01.12.2026; так же и 2026-12-01
+//	-слово            отрицание любого из условий выше
+//
+// Дата в before, after и on пишется в любом формате dates.Parse, в том числе
+// относительном: before:завтра, after:+3d. Слова вида name:value с незнакомым
+// name ищутся как обычный текст.
+type Query struct {
+	Conds []Cond
+}
+
+// Cond — условие поиска: Word, Phrase, Tag, RepeatKind, DateCmp или Not.
+type Cond interface {
+	// Match проверяет условие на задаче; так ищет хранилище в памяти.
+	Match(task *Task) bool
+}
+
+// Word — слово задачи начинается с Prefix.
+type Word struct {
+	Prefix string
+}
+
+// Phrase — слова Words идут в названии или комментарии подряд.
+type Phrase struct {
+	Words []string
+}
+
+// Tag — в названии есть метка +Name или @Name.
+type Tag struct {
+	Name string
+}
+
+// RepeatKind — вид правила повторения: any, none, d, w, m или y.
+type RepeatKind struct {
+	Kind string
+}
+
+// DateCmp сравнивает дату задачи с Date (YYYYMMDD): Op — "<", ">" или "=".
+type DateCmp struct {
+	Op   string
+	Date string
+}
+
+// Not выполняется, если не выполнено Cond.
+type Not struct {
+	Cond Cond
+}
+
+// QueryError — ошибка в строке поиска. Pos — номер символа, с 1.
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid search query at position %d: %s", e.Pos, e.Msg)
+}
+
+var repeatKinds = map[string]bool{"any": true, "none": true, "d": true, "w": true, "m": true, "y": true}
+
+var dateOps = map[string]string{"before": "<", "after": ">", "on": "="}
+
+// ParseQuery разбирает строку поиска. Пустая строка даёт пустой Query.
+func ParseQuery(search string) (*Query, error) {
+	src := []rune(search)
+	q := &Query{}
+	for i := 0; i < len(src); {
+		if unicode.IsSpace(src[i]) {
+			i++
+			continue
+		}
+		start := i
+		negate := src[i] == '-'
+		if negate {
+			i++
+			if i == len(src) || unicode.IsSpace(src[i]) {
+				return nil, &QueryError{Pos: start + 1, Msg: "'-' must be followed by a word"}
+			}
+		}
+
+		var conds []Cond
+		if src[i] == '"' {
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				end++
+			}
+			if end == len(src) {
+				return nil, &QueryError{Pos: i + 1, Msg: "unterminated quote"}
+			}
+			words := SearchTerms(string(src[i+1 : end]))
+			if len(words) == 0 {
+				return nil, &QueryError{Pos: i + 1, Msg: "empty phrase"}
+			}
+			conds = []Cond{Phrase{Words: words}}
+			i = end + 1
+		} else {
+			end := i
+			for end < len(src) && !unicode.IsSpace(src[end]) {
+				end++
+			}
+			var err error
+			if conds, err = parseWord(string(src[i:end]), negate); err != nil {
+				return nil, &QueryError{Pos: start + 1, Msg: err.Error()}
+			}
+			i = end
+		}
+
+		for _, c := range conds {
+			if negate {
+				c = Not{Cond: c}
+			}
+			q.Conds = append(q.Conds, c)
+		}
+	}
+	return q, nil
+}
+
+// parseWord разбирает слово строки поиска без кавычек и знака отрицания.
+func parseWord(word string, negate bool) ([]Cond, error) {
+	if dates.Explicit(word) {
+		date, err := dates.Normalize(word, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		return []Cond{DateCmp{Op: "=", Date: date}}, nil
+	}
+
+	if name, value, ok := strings.Cut(word, ":"); ok {
+		name = strings.ToLower(name)
+		_, isDate := dateOps[name]
+		if (name == "tag" || name == "repeat" || isDate) && value == "" {
+			return nil, fmt.Errorf("%s: needs a value", name)
+		}
+		switch {
+		case name == "tag":
+			value = strings.TrimLeft(value, "+@")
+			if value == "" || strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+				return nil, fmt.Errorf("invalid tag %q", value)
+			}
+			return []Cond{Tag{Name: strings.ToLower(value)}}, nil
+		case name == "repeat":
+			kind := strings.ToLower(value)
+			if !repeatKinds[kind] {
+				return nil, fmt.Errorf("repeat: must be any, none, d, w, m or y, got %q", value)
+			}
+			return []Cond{RepeatKind{Kind: kind}}, nil
+		case isDate:
+			date, err := dates.Normalize(value, time.Now().UTC())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return []Cond{DateCmp{Op: dateOps[name], Date: date}}, nil
+		}
+	}
+
+	// Как и раньше, слово делится на части по знакам препинания. Отрицание
+	// относится ко всему слову, поэтому его части ищутся как фраза.
+	words := SearchTerms(word)
+	if negate && len(words) > 1 {
+		return []Cond{Phrase{Words: words}}, nil
+	}
+	conds := make([]Cond, len(words))
+	for i, w := range words {
+		conds[i] = Word{Prefix: w}
+	}
+	return conds, nil
+}
+
+func (c Word) Match(task *Task) bool {
+	return countPrefixed(SearchTerms(task.Title), c.Prefix) > 0 ||
+		countPrefixed(SearchTerms(task.Comment), c.Prefix) > 0
+}
+
+func (c Phrase) Match(task *Task) bool {
+	return containsRun(SearchTerms(task.Title), c.Words) || containsRun(SearchTerms(task.Comment), c.Words)
+}
+
+func containsRun(words, run []string) bool {
+	for i := 0; i+len(run) <= len(words); i++ {
+		found := true
+		for j, w := range run {
+			found = found && words[i+j] == w
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Tag) Match(task *Task) bool {
+	for _, f := range strings.Fields(strings.ToLower(task.Title)) {
+		if f == "+"+c.Name || f == "@"+c.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c RepeatKind) Match(task *Task) bool {
+	switch c.Kind {
+	case "any":
+		return task.Repeat != ""
+	case "none":
+		return task.Repeat == ""
+	case "y":
+		return task.Repeat == "y"
+	}
+	return strings.HasPrefix(task.Repeat, c.Kind+" ")
+}
+
+func (c DateCmp) Match(task *Task) bool {
+	switch c.Op {
+	case "<":
+		return task.Date < c.Date
+	case ">":
+		return task.Date > c.Date
+	}
+	return task.Date == c.Date
+}
+
+func (c Not) Match(task *Task) bool {
+	return !c.Cond.Match(task)
+}
+
+// Match проверяет все условия на задаче.
+func (q *Query) Match(task *Task) bool {
+	for _, c := range q.Conds {
+		if !c.Match(task) {
+			return false
+		}
+	}
+	return true
+}
+
+// Terms возвращает слова, которые ищутся в тексте задачи: по ним задачи
+// упорядочиваются по релевантности и выделяются в сниппете.
+func (q *Query) Terms() []string {
+	var terms []string
+	for _, c := range q.Conds {
+		switch c := c.(type) {
+		case Word:
+			terms = append(terms, c.Prefix)
+		case Phrase:
+			terms = append(terms, c.Words...)
+		}
+	}
+	return terms
+}
+
+// compiled — строка поиска, переведённая в SQL.
+type compiled struct {
+	where []string
+	args  []any
+	// match — запрос FTS5 по словам и фразам без отрицания; пустой, если
+	// их нет. В PostgreSQL слова проверяются в where.
+	match string
+}
+
+// ftsVector — выражение, по которому построен индекс GIN в PostgreSQL.
+const ftsVector = `to_tsvector('simple', title || ' ' || comment)`
+
+// compile переводит условия в параметризованный SQL для таблицы scheduler.
+func (q *Query) compile(pg bool) compiled {
+	var c compiled
+	var match []string
+	for _, cond := range q.Conds {
+		if m, ok := ftsExpr(cond); ok && !pg {
+			match = append(match, m)
+			continue
+		}
+		where, args := condSQL(cond, pg)
+		c.where = append(c.where, where)
+		c.args = append(c.args, args...)
+	}
+	c.match = strings.Join(match, " ")
+	return c
+}
+
+// ftsExpr возвращает выражение FTS5 для слова или фразы.
+func ftsExpr(cond Cond) (string, bool) {
+	switch c := cond.(type) {
+	case Word:
+		return `"` + c.Prefix + `"*`, true
+	case Phrase:
+		return `"` + strings.Join(c.Words, " ") + `"`, true
+	}
+	return "", false
+}
+
+func condSQL(cond Cond, pg bool) (string, []any) {
+	switch c := cond.(type) {
+	case Word:
+		return ftsVector + ` @@ to_tsquery('simple', ?)`, []any{c.Prefix + ":*"}
+	case Phrase:
+		return ftsVector + ` @@ phraseto_tsquery('simple', ?)`, []any{strings.Join(c.Words, " ")}
+	case Tag:
+		// Пробелы по краям позволяют искать метку как отдельное слово.
+		title := `(' ' || ` + lower(pg) + `(title) || ' ')`
+		name := escapeLike(c.Name)
+		return `(` + title + ` LIKE ? ESCAPE '\' OR ` + title + ` LIKE ? ESCAPE '\')`,
+			[]any{"% +" + name + " %", "% @" + name + " %"}
+	case RepeatKind:
+		switch c.Kind {
+		case "any":
+			return `repeat != ''`, nil
+		case "none":
+			return `repeat = ''`, nil
+		case "y":
+			return `repeat = 'y'`, nil
+		}
+		return `repeat LIKE ?`, []any{c.Kind + " %"}
+	case DateCmp:
+		return `date ` + c.Op + ` ?`, []any{c.Date}
+	case Not:
+		if m, ok := ftsExpr(c.Cond); ok && !pg {
+			return `id NOT IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)`, []any{m}
+		}
+		where, args := condSQL(c.Cond, pg)
+		return `NOT (` + where + `)`, args
+	}
+	panic(fmt.Sprintf("unknown search condition %T", cond))
+}
+
+// lower возвращает функцию перевода в нижний регистр: встроенная lower
+// в SQLite не знает кириллицы.
+func lower(pg bool) string {
+	if pg {
+		return `lower`
+	}
+	return `unicode_lower`
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(`tag:Работа repeat:any before:01.12.2026 "Точная, фраза" -лишнее купить-молоко`)
+	require.NoError(t, err)
+	assert.Equal(t, []Cond{
+		Tag{Name: "работа"},
+		RepeatKind{Kind: "any"},
+		DateCmp{Op: "<", Date: "20261201"},
+		Phrase{Words: []string{"точная", "фраза"}},
+		Not{Cond: Word{Prefix: "лишнее"}},
+		Word{Prefix: "купить"},
+		Word{Prefix: "молоко"},
+	}, q.Conds)
+	assert.Equal(t, []string{"точная", "фраза", "купить", "молоко"}, q.Terms())
+
+	q, err = ParseQuery(`01.02.2024 -tag:+дом -"e-mail" after:20240101 в 18:00 note:x`)
+	require.NoError(t, err)
+	assert.Equal(t, []Cond{
+		DateCmp{Op: "=", Date: "20240201"},
+		Not{Cond: Tag{Name: "дом"}},
+		Not{Cond: Phrase{Words: []string{"e", "mail"}}},
+		DateCmp{Op: ">", Date: "20240101"},
+		Word{Prefix: "в"},
+		Word{Prefix: "18"},
+		Word{Prefix: "00"},
+		Word{Prefix: "note"},
+		Word{Prefix: "x"},
+	}, q.Conds)
+
+	q, err = ParseQuery(`2026-12-01 after:+3d`)
+	require.NoError(t, err)
+	assert.Equal(t, []Cond{
+		DateCmp{Op: "=", Date: "20261201"},
+		DateCmp{Op: ">", Date: time.Now().UTC().AddDate(0, 0, 3).Format("20060102")},
+	}, q.Conds)
+
+	for search, pos := range map[string]int{
+		`купить "молоко`:         8,
+		`купить -`:               8,
+		`tag:`:                   1,
+		`repeat:often`:           1,
+		`работа before:когда-то`: 8,
+		`31.02.2024`:             1,
+		`""`:                     1,
+	} {
+		_, err := ParseQuery(search)
+		var qe *QueryError
+		require.ErrorAs(t, err, &qe, search)
+		assert.Equal(t, pos, qe.Pos, search)
+	}
+}
+
+func TestSQLiteQuery(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	repo := NewRepository(db)
+
+	tasks := []*Task{
+		{Date: "20261101", Title: "Отчёт +Работа", Comment: "Квартальный отчёт для отдела", Repeat: "m 1"},
+		{Date: "20261115", Title: "Созвон @работа", Comment: "Обсудить отчёт"},
+		{Date: "20261201", Title: "Купить подарки +дом_и_сад", Comment: "Отчёт не нужен", Repeat: "y"},
+		{Date: "20261020", Title: "Зарядка", Repeat: "d 1"},
+	}
+	_, err = repo.AddTasks(tasks)
+	require.NoError(t, err)
+
+	for search, want := range map[string][]string{
+		`tag:работа`:                     {"1", "2"},
+		`tag:работа repeat:any`:          {"1"},
+		`tag:дом_и_сад`:                  {"3"},
+		`tag:дом`:                        {},
+		`-tag:работа`:                    {"3", "4"},
+		`repeat:none`:                    {"2"},
+		`repeat:d`:                       {"4"},
+		`repeat:y -repeat:none`:          {"3"},
+		`before:01.12.2026 отчёт`:        {"1", "2"},
+		`after:01.11.2026 on:01.12.2026`: {"3"},
+		`"квартальный отчёт"`:            {"1"},
+		`"отчёт квартальный"`:            {},
+		`отчёт -"не нужен"`:              {"1", "2"},
+		`отчёт -обсуд`:                   {"1", "3"},
+		`ОТЧ`:                            {"1", "2", "3"},
+		`15.11.2026`:                     {"2"},
+	} {
+		found, err := repo.Tasks(10, TaskFilter{Search: search})
+		require.NoError(t, err, search)
+		ids := []string{}
+		for _, task := range found {
+			ids = append(ids, task.ID)
+		}
+		assert.ElementsMatch(t, want, ids, search)
+
+		// Хранилище в памяти проверяет те же условия через Match.
+		query, err := ParseQuery(search)
+		require.NoError(t, err)
+		ids = []string{}
+		for i, task := range tasks {
+			if query.Match(task) {
+				ids = append(ids, formatID(int64(i+1)))
+			}
+		}
+		assert.ElementsMatch(t, want, ids, search)
+	}
+
+	_, err = repo.Tasks(10, TaskFilter{Search: `"без конца`})
+	var qe *QueryError
+	assert.ErrorAs(t, err, &qe)
+}
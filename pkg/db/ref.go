@@ -0,0 +1,37 @@
+package db
+
+import "database/sql"
+
+// Источники внешних ссылок.
+const (
+	RefICal    = "ical"
+	RefTodoist = "todoist"
+	RefTrello  = "trello"
+)
+
+// ExternalRef связывает задачу с записью во внешней системе, из которой
+// она импортирована (например, с UID из файла iCalendar). По ней повторный
+// импорт того же файла пропускает уже загруженные записи. Ссылка остаётся
+// и после удаления задачи, чтобы удалённое не возвращалось при новом импорте.
+type ExternalRef struct {
+	Source string `json:"source"`
+	Ref    string `json:"ref"`
+	TaskID string `json:"task_id"`
+}
+
+// ExternalRef возвращает идентификатор задачи, импортированной из записи ref
+// источника source, или пустую строку, если такой записи ещё не было.
+func (r *Repository) ExternalRef(source, ref string) (string, error) {
+	var taskID string
+	err := r.q().QueryRow(`SELECT task_id FROM external_ref WHERE source = ? AND ref = ?`, source, ref).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return taskID, err
+}
+
+func (r *Repository) AddExternalRef(ref *ExternalRef) error {
+	_, err := r.q().Exec(`INSERT INTO external_ref (source, ref, task_id) VALUES (?, ?, ?)`,
+		ref.Source, ref.Ref, ref.TaskID)
+	return err
+}
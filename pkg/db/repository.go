@@ -0,0 +1,123 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// maxRetries — сколько раз InTx повторяет транзакцию, если строку задачи
+// успели изменить между чтением и записью.
+const maxRetries = 5
+
+// errRowChanged возвращается запросами на изменение, если версия задачи
+// не совпала с прочитанной в той же транзакции. InTx в этом случае
+// повторяет транзакцию целиком.
+var errRowChanged = errors.New("task row changed concurrently")
+
+// Repository — хранилище задач. Методы работают либо напрямую с базой,
+// либо внутри транзакции (см. InTx и Begin); каждое изменение задачи
+// записывается в историю от имени actor.
+type Repository struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	dialect dialect
+	actor   string
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db, dialect: dialectOf(db)}
+}
+
+// WithActor возвращает копию хранилища, изменения через которую
+// записываются в историю от имени actor.
+func (r *Repository) WithActor(actor string) Store {
+	c := *r
+	c.actor = actor
+	return &c
+}
+
+// Begin начинает транзакцию. Её нужно завершить через Commit или Rollback.
+func (r *Repository) Begin() (Tx, error) {
+	return r.begin()
+}
+
+func (r *Repository) begin() (*Repository, error) {
+	if r.tx != nil {
+		return nil, errors.New("transaction already started")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{db: r.db, tx: tx, dialect: r.dialect, actor: r.actor}, nil
+}
+
+func (r *Repository) Commit() error {
+	return r.tx.Commit()
+}
+
+func (r *Repository) Rollback() error {
+	return r.tx.Rollback()
+}
+
+// InTx выполняет fn в транзакции: при ошибке изменения откатываются,
+// иначе фиксируются. Если задачу изменили параллельно между чтением и
+// записью, транзакция повторяется, поэтому fn должна сама читать нужные
+// ей данные. Внутри уже открытой транзакции fn просто вызывается.
+func (r *Repository) InTx(fn func(tx Store) error) error {
+	return r.inTx(func(tx *Repository) error {
+		return fn(tx)
+	})
+}
+
+func (r *Repository) inTx(fn func(tx *Repository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := r.runTx(fn)
+		if !errors.Is(err, errRowChanged) {
+			return err
+		}
+		if attempt == maxRetries {
+			return ErrConflict
+		}
+	}
+}
+
+func (r *Repository) runTx(fn func(tx *Repository) error) error {
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Savepoint создаёт точку сохранения, к которой можно откатиться через RollbackTo.
+func (r *Repository) Savepoint(name string) error {
+	_, err := r.tx.Exec(`SAVEPOINT ` + name)
+	return err
+}
+
+func (r *Repository) RollbackTo(name string) error {
+	_, err := r.tx.Exec(`ROLLBACK TO ` + name)
+	return err
+}
+
+func (r *Repository) Release(name string) error {
+	_, err := r.tx.Exec(`RELEASE ` + name)
+	return err
+}
+
+// q возвращает, через что выполнять запросы: транзакцию или базу.
+func (r *Repository) q() querier {
+	if r.tx != nil {
+		return wrap(r.tx, r.dialect)
+	}
+	return wrap(r.db, r.dialect)
+}
@@ -0,0 +1,329 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	testRepository(t, db)
+}
+
+// TestPostgresRepository проверяет то же самое на PostgreSQL. Тест использует
+// базу из TODO_TEST_POSTGRES_DSN и удаляет из неё все таблицы планировщика.
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv("TODO_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TODO_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := Connect(dsn)
+	require.NoError(t, err)
+	_, err = db.Exec(`DROP TABLE IF EXISTS scheduler, template, template_task, revision, feed, external_ref, saved_search, schema_version`)
+	require.NoError(t, err)
+	db.Close()
+
+	db, err = Open(dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), version)
+
+	testRepository(t, db)
+}
+
+func testRepository(t *testing.T, db *sql.DB) {
+	repo := NewRepository(db).WithActor("tester")
+
+	id, err := repo.AddTask(&Task{Date: "20240101", Title: "Зарядка", Comment: "Утром", Repeat: "d 1"})
+	require.NoError(t, err)
+	taskID := formatID(id)
+
+	tasks, err := repo.Tasks(10, TaskFilter{Search: "Утр"})
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+	tasks, err = repo.Tasks(10, TaskFilter{Search: "01.01.2024", Statuses: ActiveStatuses})
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+
+	task, err := repo.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), task.Version)
+
+	task.Title = "Зарядка и растяжка"
+	require.NoError(t, repo.UpdateTask(task))
+	assert.Equal(t, int64(2), task.Version)
+
+	task.Version = 1
+	assert.ErrorIs(t, repo.UpdateTask(task), ErrConflict)
+
+	// Каждое из параллельных завершений сдвигает задачу ровно на один день.
+	const parallel = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, parallel)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.CompleteTask(taskID, 0, func(task *Task) (string, error) {
+				return nextDay(task.Date), nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	task, err = repo.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "20240111", task.Date)
+	assert.Equal(t, int64(2+parallel), task.Version)
+
+	require.NoError(t, repo.DeleteTask(taskID, 0))
+	_, err = repo.GetTask(taskID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	history, err := repo.History(taskID)
+	require.NoError(t, err)
+	require.Len(t, history, 3+parallel)
+	assert.Equal(t, "tester", history[0].Actor)
+
+	today := time.Now().UTC().Format("20060102")
+	completions, err := repo.Completions(today, today)
+	require.NoError(t, err)
+	require.Len(t, completions, parallel)
+	assert.Equal(t, ActionDone, completions[0].Action)
+	assert.Equal(t, "20240101", completions[0].Before.Date)
+	completions, err = repo.Completions("20240101", "20240131")
+	require.NoError(t, err)
+	assert.Empty(t, completions)
+	lastRev, err := repo.LastRevisionID()
+	require.NoError(t, err)
+	assert.Equal(t, history[len(history)-1].ID, formatID(lastRev))
+
+	restored, err := repo.RevertTask(taskID, history[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Зарядка и растяжка", restored.Title)
+	assert.Equal(t, "20240101", restored.Date)
+
+	// RestoreTask не проверяет переходы: выполненная задача снова в работе.
+	restored.Status = StatusDone
+	require.NoError(t, repo.RestoreTask(restored))
+	restored.Status = StatusInProgress
+	require.NoError(t, repo.RestoreTask(restored))
+	task, err = repo.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusInProgress, task.Status)
+	restored.Version--
+	assert.ErrorIs(t, repo.RestoreTask(restored), ErrConflict)
+
+	// Перенос запоминает дату повтора, выполнение и правка даты её сбрасывают.
+	require.NoError(t, repo.UpdateDate("20240105", taskID))
+	require.NoError(t, repo.UpdateDate("20240107", taskID))
+	task, err = repo.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101", task.Anchor)
+	require.NoError(t, repo.UpdateTask(task))
+	assert.Equal(t, "20240101", task.Anchor)
+	require.NoError(t, repo.CompleteTask(taskID, 0, func(task *Task) (string, error) {
+		return "20240108", nil
+	}))
+	task, err = repo.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Empty(t, task.Anchor)
+	require.NoError(t, repo.UpdateDate("20240110", taskID))
+	task, err = repo.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "20240108", task.Anchor)
+	task.Date = "20240101"
+	require.NoError(t, repo.UpdateTask(task))
+	assert.Empty(t, task.Anchor)
+
+	tplID, err := repo.AddTemplate(&Template{Name: "Онбординг", Tasks: []*TemplateTask{
+		{Offset: 0, Title: "Выдать ноутбук"},
+		{Offset: 3, Title: "Созвон с ментором", Repeat: "d 7"},
+	}})
+	require.NoError(t, err)
+	tpl, err := repo.GetTemplate(formatID(tplID))
+	require.NoError(t, err)
+	assert.Len(t, tpl.Tasks, 2)
+
+	tx, err := repo.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.Savepoint("sp"))
+	_, err = tx.AddTask(&Task{Date: "20240102", Title: "Откатится"})
+	require.NoError(t, err)
+	require.NoError(t, tx.RollbackTo("sp"))
+	require.NoError(t, tx.Release("sp"))
+	_, err = tx.AddTask(&Task{Date: "20240103", Title: "Останется"})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	tasks, err = repo.Tasks(10, TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "Останется", tasks[1].Title)
+
+	taskID, err = repo.ExternalRef(RefICal, "uid-1@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, taskID)
+	require.NoError(t, repo.AddExternalRef(&ExternalRef{Source: RefICal, Ref: "uid-1@example.com", TaskID: tasks[1].ID}))
+	assert.Error(t, repo.AddExternalRef(&ExternalRef{Source: RefICal, Ref: "uid-1@example.com", TaskID: tasks[0].ID}))
+	taskID, err = repo.ExternalRef(RefICal, "uid-1@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, tasks[1].ID, taskID)
+
+	searchID, err := repo.AddSavedSearch(&SavedSearch{Name: "Работа", Query: "tag:работа", Sort: SortTitle})
+	require.NoError(t, err)
+	saved, err := repo.GetSavedSearch(formatID(searchID))
+	require.NoError(t, err)
+	assert.Equal(t, "tag:работа", saved.Query)
+	saved.Query = "tag:работа repeat:any"
+	require.NoError(t, repo.UpdateSavedSearch(saved))
+	searches, err := repo.SavedSearches()
+	require.NoError(t, err)
+	assert.Equal(t, []*SavedSearch{saved}, searches)
+	require.NoError(t, repo.DeleteSavedSearch(saved.ID))
+	assert.ErrorIs(t, repo.DeleteSavedSearch(saved.ID), ErrSavedSearchNotFound)
+	_, err = repo.GetSavedSearch(saved.ID)
+	assert.ErrorIs(t, err, ErrSavedSearchNotFound)
+
+	// Отсутствующая задача восстанавливается с прежним id, если версия не задана.
+	assert.ErrorIs(t, repo.RestoreTask(&Task{ID: "100", Date: "20240101", Title: "Из выгрузки", Version: 1}), ErrNotFound)
+	imported := &Task{ID: "100", Date: "20240101", Title: "Из выгрузки", Status: StatusWaiting}
+	require.NoError(t, repo.RestoreTask(imported))
+	task, err = repo.GetTask("100")
+	require.NoError(t, err)
+	assert.Equal(t, StatusWaiting, task.Status)
+	assert.Equal(t, imported.Version, task.Version)
+	id, err = repo.AddTask(&Task{Date: "20240101", Title: "После выгрузки"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), id)
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	repo := NewRepository(db)
+
+	_, err = repo.AddTasks([]*Task{
+		{Date: "20240101", Title: "Купить продукты", Comment: "Молоко и <хлеб> для завтрака"},
+		{Date: "20240102", Title: "Завтрак с командой", Comment: "Кафе у офиса"},
+		{Date: "20240103", Title: "Позвонить маме", Comment: "Спросить про завтра"},
+	})
+	require.NoError(t, err)
+
+	search := func(s string) []*Task {
+		tasks, err := repo.Tasks(10, TaskFilter{Search: s})
+		require.NoError(t, err)
+		return tasks
+	}
+
+	// Регистр кириллицы не важен, слова ищутся по началу.
+	tasks := search("ЗАВТР")
+	require.Len(t, tasks, 3)
+	assert.Equal(t, "Завтрак с командой", tasks[0].Title, "совпадение в названии выше")
+	for _, task := range tasks[1:] {
+		if task.Title == "Купить продукты" {
+			assert.Equal(t, "Молоко и &lt;хлеб&gt; для <mark>завтрака</mark>", task.Snippet)
+		}
+	}
+
+	tasks = search("купить молоко")
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "<mark>Купить</mark> продукты", tasks[0].Snippet)
+	assert.Empty(t, search("офис кафе маме"))
+	assert.Len(t, search("02.01.2024"), 1)
+
+	// Индекс следует за изменением и удалением задач.
+	task := tasks[0]
+	task.Title = "Купить хлеб"
+	require.NoError(t, repo.UpdateTask(task))
+	assert.Empty(t, search("продукты"))
+	assert.Len(t, search("хлеб"), 1)
+	require.NoError(t, repo.DeleteTask(task.ID, 0))
+	assert.Empty(t, search("хлеб"))
+}
+
+func TestSQLitePages(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	repo := NewRepository(db)
+
+	_, err = repo.AddTasks([]*Task{
+		{Date: "20240102", Title: "Полить цветы"},
+		{Date: "20240101", Title: "Полить огород"},
+		{Date: "20240102", Title: "Покормить кота"},
+	})
+	require.NoError(t, err)
+
+	filter := TaskFilter{}
+	page, err := repo.Tasks(2, filter)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, []string{"2", "1"}, []string{page[0].ID, page[1].ID})
+	filter.After = filter.NextCursor(page)
+	assert.Equal(t, &Cursor{Date: "20240102", ID: 1}, filter.After)
+	page, err = repo.Tasks(2, filter)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "3", page[0].ID)
+
+	filter = TaskFilter{Search: "полить"}
+	page, err = repo.Tasks(1, filter)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	filter.After = filter.NextCursor(page)
+	assert.Equal(t, &Cursor{Offset: 1}, filter.After)
+	next, err := repo.Tasks(2, filter)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	assert.NotEqual(t, page[0].ID, next[0].ID)
+
+	_, err = repo.AddTask(&Task{Date: "20231201", Title: "Сдать показания", Repeat: "m 25"})
+	require.NoError(t, err)
+	page, err = repo.Tasks(10, TaskFilter{From: "20240102", To: "20240131"})
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+	page, err = repo.Tasks(10, TaskFilter{From: "20240102", To: "20240131", Repeating: true})
+	require.NoError(t, err)
+	assert.Len(t, page, 3)
+
+	filter = TaskFilter{Sort: SortTitle}
+	page, err = repo.Tasks(2, filter)
+	require.NoError(t, err)
+	assert.Equal(t, "Покормить кота", page[0].Title)
+	filter.After = filter.NextCursor(page)
+	page, err = repo.Tasks(2, filter)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "Полить цветы", page[0].Title)
+	assert.Equal(t, "Сдать показания", page[1].Title)
+}
+
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func nextDay(date string) string {
+	t, _ := time.Parse("20060102", date)
+	return t.AddDate(0, 0, 1).Format("20060102")
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Действия, которые записываются в историю задачи.
+const (
+	ActionInsert = "insert"
+	ActionUpdate = "update"
+	ActionDate   = "date"
+	ActionStatus = "status"
+	ActionDone   = "done"
+	ActionDelete = "delete"
+	ActionRevert = "revert"
+)
+
+// ErrRevisionNotFound возвращается, если у задачи нет ревизии с указанным идентификатором.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision — запись истории изменений задачи со снимками до и после изменения.
+// Before пуст для создания задачи, After — для удаления.
+type Revision struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	Before    *Task  `json:"before"`
+	After     *Task  `json:"after"`
+	CreatedAt string `json:"created_at"`
+}
+
+// History возвращает историю изменений задачи от старых записей к новым.
+func (r *Repository) History(taskID string) ([]*Revision, error) {
+	query := `SELECT id, task_id, action, actor, before, after, created_at FROM revision WHERE task_id = ? ORDER BY id`
+	rows, err := r.q().Query(query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*Revision, 0)
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// Completions возвращает отметки о выполнении (ревизии ActionDone), сделанные
+// с from по to включительно, от старых к новым. Даты — в формате YYYYMMDD, по UTC.
+func (r *Repository) Completions(from, to string) ([]*Revision, error) {
+	start, end, err := revisionSpan(from, to)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id, task_id, action, actor, before, after, created_at FROM revision
+WHERE action = ? AND created_at >= ? AND created_at < ? ORDER BY id`
+	rows, err := r.q().Query(query, ActionDone, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*Revision, 0)
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// LastRevisionID возвращает идентификатор последней ревизии или 0, если
+// истории ещё нет. Он меняется при любом изменении задач, поэтому годится
+// как признак того, что посчитанное по задачам устарело.
+func (r *Repository) LastRevisionID() (int64, error) {
+	var id int64
+	err := r.q().QueryRow(`SELECT COALESCE(MAX(id), 0) FROM revision`).Scan(&id)
+	return id, err
+}
+
+// revisionSpan переводит дни from..to в границы created_at: начало from
+// включительно и начало следующего за to дня не включительно.
+func revisionSpan(from, to string) (start, end string, err error) {
+	first, err := time.Parse("20060102", from)
+	if err != nil {
+		return "", "", err
+	}
+	last, err := time.Parse("20060102", to)
+	if err != nil {
+		return "", "", err
+	}
+	return first.Format(time.RFC3339), last.AddDate(0, 0, 1).Format(time.RFC3339), nil
+}
+
+// lastVersion возвращает версию, которую задача получит при восстановлении
+// после удаления: на единицу больше последней известной по истории.
+func lastVersion(q querier, taskID string) (int64, error) {
+	query := `SELECT before, after FROM revision WHERE task_id = ? ORDER BY id DESC LIMIT 1`
+	var before, after string
+	if err := q.QueryRow(query, taskID).Scan(&before, &after); err != nil {
+		if err == sql.ErrNoRows {
+			return 1, nil
+		}
+		return 0, err
+	}
+
+	snapshot, err := unmarshalSnapshot(after)
+	if err == nil && snapshot == nil {
+		snapshot, err = unmarshalSnapshot(before)
+	}
+	if err != nil || snapshot == nil {
+		return 1, err
+	}
+	return snapshot.Version + 1, nil
+}
+
+func getRevision(q querier, id string) (*Revision, error) {
+	query := `SELECT id, task_id, action, actor, before, after, created_at FROM revision WHERE id = ?`
+	rev, err := scanRevision(q.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	}
+	return rev, err
+}
+
+func addRevision(q querier, rev *Revision) error {
+	before, err := marshalSnapshot(rev.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalSnapshot(rev.After)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO revision (task_id, action, actor, before, after, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = q.Exec(query, rev.TaskID, rev.Action, rev.Actor, before, after, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func scanRevision(row interface{ Scan(...any) error }) (*Revision, error) {
+	rev := &Revision{}
+	var before, after string
+	if err := row.Scan(&rev.ID, &rev.TaskID, &rev.Action, &rev.Actor, &before, &after, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if rev.Before, err = unmarshalSnapshot(before); err != nil {
+		return nil, err
+	}
+	if rev.After, err = unmarshalSnapshot(after); err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+func marshalSnapshot(task *Task) (string, error) {
+	if task == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(task)
+	return string(data), err
+}
+
+func unmarshalSnapshot(data string) (*Task, error) {
+	if data == "" {
+		return nil, nil
+	}
+	task := &Task{}
+	return task, json.Unmarshal([]byte(data), task)
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrSavedSearchNotFound возвращается, если сохранённого поиска с таким
+// идентификатором нет.
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// SavedSearch — сохранённый поиск, который клиент показывает как список
+// задач: строка поиска в языке ParseQuery и порядок задач (см. TaskFilter.Sort).
+type SavedSearch struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Sort  string `json:"sort"`
+}
+
+const savedSearchColumns = `id, name, query, sort`
+
+func (r *Repository) SavedSearches() ([]*SavedSearch, error) {
+	rows, err := r.q().Query(`SELECT ` + savedSearchColumns + ` FROM saved_search ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := make([]*SavedSearch, 0)
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+func (r *Repository) GetSavedSearch(id string) (*SavedSearch, error) {
+	query := `SELECT ` + savedSearchColumns + ` FROM saved_search WHERE id = ?`
+	s, err := scanSavedSearch(r.q().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrSavedSearchNotFound
+	}
+	return s, err
+}
+
+func (r *Repository) AddSavedSearch(s *SavedSearch) (int64, error) {
+	query := `INSERT INTO saved_search (name, query, sort) VALUES (?, ?, ?)`
+	return insert(r.q(), query, s.Name, s.Query, s.Sort)
+}
+
+func (r *Repository) UpdateSavedSearch(s *SavedSearch) error {
+	res, err := r.q().Exec(`UPDATE saved_search SET name = ?, query = ?, sort = ? WHERE id = ?`,
+		s.Name, s.Query, s.Sort, s.ID)
+	return savedSearchAffected(res, err)
+}
+
+func (r *Repository) DeleteSavedSearch(id string) error {
+	res, err := r.q().Exec(`DELETE FROM saved_search WHERE id = ?`, id)
+	return savedSearchAffected(res, err)
+}
+
+func savedSearchAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+func scanSavedSearch(row interface{ Scan(...any) error }) (*SavedSearch, error) {
+	s := &SavedSearch{}
+	if err := row.Scan(&s.ID, &s.Name, &s.Query, &s.Sort); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
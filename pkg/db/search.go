@@ -0,0 +1,174 @@
+package db
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Границы выделенного слова в сниппете до экранирования HTML.
+const (
+	markStart = "\x01"
+	markEnd   = "\x02"
+)
+
+// snippetWords — сколько слов показывается в сниппете.
+const snippetWords = 10
+
+// SearchTerms разбивает строку поиска на слова в нижнем регистре. Знаки
+// препинания и операторы FTS5 отбрасываются.
+func SearchTerms(search string) []string {
+	return strings.FieldsFunc(strings.ToLower(search), notWordRune)
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// tsQuery составляет запрос to_tsquery для PostgreSQL: каждое слово ищется как
+// начало слова в названии или комментарии.
+func tsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = term + ":*"
+	}
+	return strings.Join(quoted, " & ")
+}
+
+// SearchRank оценивает, насколько задача подходит под слова поиска: слово
+// в названии весит больше, чем в комментарии. Ноль означает, что какое-то
+// из слов не найдено.
+func SearchRank(task *Task, terms []string) int {
+	title, comment := SearchTerms(task.Title), SearchTerms(task.Comment)
+	rank := 0
+	for _, term := range terms {
+		hits := 10*countPrefixed(title, term) + countPrefixed(comment, term)
+		if hits == 0 {
+			return 0
+		}
+		rank += hits
+	}
+	return rank
+}
+
+// SortByRank упорядочивает найденные задачи по убыванию SearchRank; задачи
+// с равной оценкой сохраняют порядок.
+func SortByRank(tasks []*Task, terms []string) {
+	ranks := make(map[*Task]int, len(tasks))
+	for _, task := range tasks {
+		ranks[task] = SearchRank(task, terms)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return ranks[tasks[i]] > ranks[tasks[j]]
+	})
+}
+
+// Snippet возвращает фрагмент названия или комментария вокруг первого
+// найденного слова. Найденные слова обёрнуты в <mark>, остальной текст
+// экранирован для HTML.
+func Snippet(task *Task, terms []string) string {
+	for _, text := range []string{task.Title, task.Comment} {
+		if s, ok := snippet(text, terms); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func snippet(text string, terms []string) (string, bool) {
+	tokens := tokenize(text)
+	var wordIdx []int
+	first := -1
+	for i, tok := range tokens {
+		if !tok.word {
+			continue
+		}
+		if first < 0 && matchesAny(tok.text, terms) {
+			first = len(wordIdx)
+		}
+		wordIdx = append(wordIdx, i)
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	from := max(0, min(first-snippetWords/2, len(wordIdx)-snippetWords))
+	to := min(len(wordIdx), from+snippetWords)
+	start, end := wordIdx[from], wordIdx[to-1]+1
+	if from == 0 {
+		start = 0
+	}
+	if to == len(wordIdx) {
+		end = len(tokens)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for _, tok := range tokens[start:end] {
+		if tok.word && matchesAny(tok.text, terms) {
+			b.WriteString(markStart + tok.text + markEnd)
+		} else {
+			b.WriteString(tok.text)
+		}
+	}
+	if end < len(tokens) {
+		b.WriteString("…")
+	}
+	return markSnippet(b.String()), true
+}
+
+// markSnippet экранирует сниппет для HTML и заменяет границы найденных
+// слов на теги <mark>.
+func markSnippet(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, markStart, "<mark>")
+	return strings.ReplaceAll(s, markEnd, "</mark>")
+}
+
+type token struct {
+	text string
+	word bool
+}
+
+// tokenize делит текст на слова и промежутки между ними.
+func tokenize(text string) []token {
+	var tokens []token
+	start := 0
+	for i, r := range text {
+		word := !notWordRune(r)
+		if i > start && word != tokens[len(tokens)-1].word {
+			tokens[len(tokens)-1].text = text[start:i]
+			start = i
+		}
+		if i == start {
+			tokens = append(tokens, token{word: word})
+		}
+	}
+	if len(tokens) > 0 {
+		tokens[len(tokens)-1].text = text[start:]
+	}
+	return tokens
+}
+
+func countPrefixed(words []string, term string) int {
+	n := 0
+	for _, w := range words {
+		if strings.HasPrefix(w, term) {
+			n++
+		}
+	}
+	return n
+}
+
+func matchesAny(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
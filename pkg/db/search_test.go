@@ -0,0 +1,24 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnippet(t *testing.T) {
+	terms := SearchTerms("Завтр, <b>")
+	assert.Equal(t, []string{"завтр", "b"}, terms)
+
+	task := &Task{Title: "Позвонить маме", Comment: "Спросить про завтрак & <b>обед</b>"}
+	assert.Equal(t, "Спросить про <mark>завтрак</mark> &amp; &lt;<mark>b</mark>&gt;обед&lt;/<mark>b</mark>&gt;", Snippet(task, terms))
+	assert.Equal(t, 2, SearchRank(task, []string{"завтр"})+SearchRank(task, []string{"обед"}))
+	assert.Zero(t, SearchRank(task, []string{"завтр", "ужин"}))
+
+	task = &Task{Title: "раз два три четыре пять шесть семь восемь девять десять одиннадцать двенадцать"}
+	assert.Equal(t, "…два три четыре пять шесть <mark>семь</mark> восемь девять десять одиннадцать…",
+		Snippet(task, []string{"семь"}))
+	assert.Equal(t, "<mark>раз</mark> два три четыре пять шесть семь восемь девять десять…",
+		Snippet(task, []string{"раз"}))
+	assert.Empty(t, Snippet(task, []string{"ноль"}))
+}
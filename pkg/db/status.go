@@ -0,0 +1,51 @@
+package db
+
+import "fmt"
+
+// Статусы задачи.
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusWaiting    = "waiting"
+	StatusDone       = "done"
+)
+
+// ActiveStatuses — статусы незавершённых задач, которые показываются в списке по умолчанию.
+var ActiveStatuses = []string{StatusTodo, StatusInProgress, StatusWaiting}
+
+// transitions описывает допустимые переходы между статусами.
+// Завершённую задачу можно только вернуть в работу.
+var transitions = map[string][]string{
+	StatusTodo:       {StatusInProgress, StatusWaiting, StatusDone},
+	StatusInProgress: {StatusTodo, StatusWaiting, StatusDone},
+	StatusWaiting:    {StatusTodo, StatusInProgress, StatusDone},
+	StatusDone:       {StatusTodo},
+}
+
+// ValidStatus сообщает, известен ли статус.
+func ValidStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition сообщает, разрешён ли переход из статуса from в статус to.
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError возвращается, если переход между статусами запрещён.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	if e.From == StatusDone && e.To == StatusDone {
+		return "task is already done"
+	}
+	return fmt.Sprintf("cannot change status from %s to %s", e.From, e.To)
+}
@@ -0,0 +1,71 @@
+package db
+
+// Store — хранилище задач, с которым работают обработчики API.
+// Его реализуют Repository (SQLite и PostgreSQL), memstore.Store (в памяти)
+// и хранилище в файлах из pkg/db/filestore.
+//
+// Изменения задач записываются в историю от имени автора, заданного через
+// WithActor. Если задача изменилась с версии, переданной вызывающей стороной,
+// методы возвращают ErrConflict, а отсутствующая задача — ErrNotFound.
+type Store interface {
+	Tasks(limit int, filter TaskFilter) ([]*Task, error)
+	// EachTask вызывает fn для каждой задачи, включая выполненные,
+	// в порядке дат. Ошибка fn прерывает обход.
+	EachTask(fn func(task *Task) error) error
+	GetTask(id string) (*Task, error)
+	AddTask(task *Task) (int64, error)
+	AddTasks(tasks []*Task) ([]int64, error)
+	UpdateTask(task *Task) error
+	// RestoreTask записывает все поля задачи вместе со статусом, не проверяя
+	// переходы статусов: так восстанавливаются данные, например при импорте.
+	// Отсутствующая задача при нулевой версии создаётся с прежним ID.
+	RestoreTask(task *Task) error
+	DeleteTask(id string, version int64) error
+	UpdateDate(next string, id string) error
+	UpdateStatus(id string, status string) error
+	CompleteTask(id string, version int64, next func(task *Task) (string, error)) error
+
+	History(taskID string) ([]*Revision, error)
+	RevertTask(id string, revisionID string) (*Task, error)
+	Completions(from, to string) ([]*Revision, error)
+	LastRevisionID() (int64, error)
+
+	Templates() ([]*Template, error)
+	GetTemplate(id string) (*Template, error)
+	AddTemplate(tpl *Template) (int64, error)
+	DeleteTemplate(id string) error
+
+	Feeds() ([]*Feed, error)
+	FeedByToken(tokenHash string) (*Feed, error)
+	AddFeed(feed *Feed) (int64, error)
+	DeleteFeed(id string) error
+
+	SavedSearches() ([]*SavedSearch, error)
+	GetSavedSearch(id string) (*SavedSearch, error)
+	AddSavedSearch(s *SavedSearch) (int64, error)
+	UpdateSavedSearch(s *SavedSearch) error
+	DeleteSavedSearch(id string) error
+
+	ExternalRef(source, ref string) (string, error)
+	AddExternalRef(ref *ExternalRef) error
+
+	// WithActor возвращает хранилище, изменения через которое
+	// записываются в историю от имени actor.
+	WithActor(actor string) Store
+	// InTx выполняет fn в транзакции: при ошибке изменения откатываются.
+	InTx(fn func(tx Store) error) error
+	// Begin начинает транзакцию, которую нужно завершить через Commit или Rollback.
+	Begin() (Tx, error)
+}
+
+// Tx — открытая транзакция хранилища с точками сохранения.
+type Tx interface {
+	Store
+	Commit() error
+	Rollback() error
+	Savepoint(name string) error
+	RollbackTo(name string) error
+	Release(name string) error
+}
+
+var _ Tx = (*Repository)(nil)
@@ -2,14 +2,19 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
+	"errors"
+	"strconv"
+	"strings"
 )
 
-var db *sql.DB
+var (
+	// ErrNotFound возвращается, если задача с указанным идентификатором не существует.
+	ErrNotFound = errors.New("task not found")
+	// ErrConflict возвращается, если задача изменилась с тех пор, как её прочитали.
+	ErrConflict = errors.New("task was modified by another request")
+)
 
-func SetDB(database *sql.DB) {
-	db = database
-}
+const taskColumns = `id, date, title, comment, repeat, status, version, anchor`
 
 type Task struct {
 	ID      string `json:"id"`
@@ -17,40 +22,205 @@ type Task struct {
 	Title   string `json:"title"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
+	Status  string `json:"status"`
+	Version int64  `json:"version,string"`
+	// Anchor — дата повтора, с которой отложили повторяющуюся задачу.
+	// Следующий повтор считается от неё, поэтому перенос не сдвигает
+	// расписание. Пустая, если задачу не откладывали.
+	Anchor string `json:"anchor,omitempty"`
+	// Snippet — фрагмент названия или комментария с выделенными словами
+	// поиска; заполняется только в результатах поиска.
+	Snippet string `json:"snippet,omitempty"`
+	// Virtual отмечает повтор задачи на другую дату. Такие повторы не
+	// хранятся, а вычисляются по правилу повторения.
+	Virtual bool `json:"virtual,omitempty"`
 }
 
-func AddTask(task *Task) (int64, error) {
-	query := `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`
-	res, err := db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat)
-	if err != nil {
-		return 0, err
+// SnoozedAnchor возвращает Anchor задачи после переноса на дату next.
+// У разовой задачи расписания нет; перенос обратно на дату повтора
+// возвращает задачу в расписание.
+func (t *Task) SnoozedAnchor(next string) string {
+	if t.Repeat == "" {
+		return ""
+	}
+	anchor := t.Anchor
+	if anchor == "" {
+		anchor = t.Date
+	}
+	if anchor == next {
+		return ""
+	}
+	return anchor
+}
+
+// EditedAnchor возвращает Anchor задачи после правки на changed: новая
+// дата или правило повторения задают расписание заново.
+func (t *Task) EditedAnchor(changed *Task) string {
+	if changed.Date != t.Date || changed.Repeat != t.Repeat {
+		return ""
+	}
+	return t.Anchor
+}
+
+// TaskFilter задаёт условия отбора задач для Tasks.
+type TaskFilter struct {
+	Search   string
+	Statuses []string
+	// From и To ограничивают дату задачи включительно, в формате YYYYMMDD;
+	// пустая граница не ограничивает.
+	From string
+	To   string
+	// Repeating отбирает и повторяющиеся задачи с датой раньше From: их
+	// следующие повторы ещё могут попасть в диапазон.
+	Repeating bool
+	// Sort — порядок задач: SortDate, SortTitle или SortRelevance. Пустой
+	// означает релевантность, если в строке поиска есть слова, иначе дату.
+	Sort string
+	// After — курсор предыдущей страницы; nil означает первую страницу.
+	After *Cursor
+}
+
+// Порядок задач в Tasks.
+const (
+	SortDate      = "date"
+	SortTitle     = "title"
+	SortRelevance = "relevance"
+)
+
+// ValidSort сообщает, известен ли порядок sort; пустой допустим.
+func ValidSort(sort string) bool {
+	return sort == "" || sort == SortDate || sort == SortTitle || sort == SortRelevance
+}
+
+// Order возвращает порядок, в котором Tasks выдаёт задачи. Релевантность
+// без слов поиска совпадает с порядком по дате.
+func (f TaskFilter) Order() string {
+	if f.Sort == SortTitle {
+		return SortTitle
+	}
+	if f.Sort == SortDate {
+		return SortDate
+	}
+	if q, err := ParseQuery(f.Search); err == nil && len(q.Terms()) > 0 {
+		return SortRelevance
 	}
-	return res.LastInsertId()
+	return SortDate
 }
 
-func Tasks(limit int, search string) ([]*Task, error) {
-	var query string
-	var args []interface{}
+// InRange сообщает, попадает ли дата задачи в границы From и To.
+func (f TaskFilter) InRange(task *Task) bool {
+	return (f.From == "" || task.Date >= f.From || f.Repeating && task.Repeat != "") &&
+		(f.To == "" || task.Date <= f.To)
+}
+
+// Cursor указывает, где закончилась предыдущая страница Tasks. Задачи,
+// упорядоченные по дате, продолжаются после даты и идентификатора последней
+// задачи. Другие порядки не задают устойчивого ключа, поэтому для них курсор
+// хранит число уже выданных задач.
+type Cursor struct {
+	Date   string `json:"d,omitempty"`
+	ID     int64  `json:"i,omitempty"`
+	Offset int    `json:"o,omitempty"`
+}
+
+// NextCursor возвращает курсор страницы, следующей за page.
+func (f TaskFilter) NextCursor(page []*Task) *Cursor {
+	if f.Order() != SortDate {
+		offset := len(page)
+		if f.After != nil {
+			offset += f.After.Offset
+		}
+		return &Cursor{Offset: offset}
+	}
+	last := page[len(page)-1]
+	id, _ := strconv.ParseInt(last.ID, 10, 64)
+	return &Cursor{Date: last.Date, ID: id}
+}
+
+// offset возвращает, сколько задач пропустить, если задачи упорядочены не по дате.
+func (f TaskFilter) offset() int {
+	if f.After == nil || f.Order() == SortDate {
+		return 0
+	}
+	return f.After.Offset
+}
+
+// Tasks возвращает задачи, подходящие под filter, в порядке filter.Order().
+// Строка поиска разбирается ParseQuery; ошибка в ней возвращается как
+// *QueryError.
+func (r *Repository) Tasks(limit int, filter TaskFilter) ([]*Task, error) {
+	search, err := ParseQuery(filter.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.q()
+	_, pg := q.(pgQuerier)
+	c := search.compile(pg)
+	terms := search.Terms()
+	where, args := c.where, c.args
+
+	from := `scheduler`
+	order := `date, id`
+	var orderArgs []any
+	if c.match != "" {
+		// Индекс FTS5 поддерживается триггерами, см. миграцию 8. В bm25
+		// название весит больше комментария; меньшее значение — лучше.
+		from = `scheduler JOIN (SELECT rowid, bm25(scheduler_fts, 10.0, 1.0) AS rank,
+    snippet(scheduler_fts, -1, char(1), char(2), '…', ` + strconv.Itoa(snippetWords) + `) AS snippet
+FROM scheduler_fts WHERE scheduler_fts MATCH ?) AS found ON found.rowid = scheduler.id`
+		args = append([]any{c.match}, args...)
+	}
+	switch filter.Order() {
+	case SortTitle:
+		order = lower(pg) + `(title), date, id`
+	case SortRelevance:
+		if !pg {
+			order = `found.rank, date, id`
+			break
+		}
+		order = `ts_rank(setweight(to_tsvector('simple', title), 'A') || to_tsvector('simple', comment), to_tsquery('simple', ?)) DESC, date, id`
+		orderArgs = append(orderArgs, tsQuery(terms))
+	}
 
-	if search == "" {
-		query = `SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date LIMIT ?`
-		args = []interface{}{limit}
-	} else {
-		// Check if search is a date in format DD.MM.YYYY
-		if len(search) == 10 && search[2] == '.' && search[5] == '.' {
-			// Convert DD.MM.YYYY to YYYYMMDD
-			date := search[6:10] + search[3:5] + search[0:2]
-			query = `SELECT id, date, title, comment, repeat FROM scheduler WHERE date = ? ORDER BY date LIMIT ?`
-			args = []interface{}{date, limit}
+	if filter.From != "" {
+		if filter.Repeating {
+			where = append(where, `(date >= ? OR repeat != '')`)
 		} else {
-			// Search in title and comment
-			searchPattern := "%" + search + "%"
-			query = `SELECT id, date, title, comment, repeat FROM scheduler WHERE title LIKE ? OR comment LIKE ? ORDER BY date LIMIT ?`
-			args = []interface{}{searchPattern, searchPattern, limit}
+			where = append(where, `date >= ?`)
 		}
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where = append(where, `date <= ?`)
+		args = append(args, filter.To)
+	}
+
+	if len(filter.Statuses) > 0 {
+		where = append(where, `status IN (?`+strings.Repeat(`, ?`, len(filter.Statuses)-1)+`)`)
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	if after := filter.After; after != nil && filter.Order() == SortDate {
+		where = append(where, `(date > ? OR (date = ? AND id > ?))`)
+		args = append(args, after.Date, after.Date, after.ID)
 	}
 
-	rows, err := db.Query(query, args...)
+	columns := taskColumns
+	if c.match != "" {
+		columns += `, found.snippet`
+	}
+	query := `SELECT ` + columns + ` FROM ` + from
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+	args = append(args, orderArgs...)
+	args = append(args, limit, filter.offset())
+
+	rows, err := q.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -59,10 +229,18 @@ func Tasks(limit int, search string) ([]*Task, error) {
 	var tasks []*Task
 	for rows.Next() {
 		task := &Task{}
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
-		if err != nil {
+		dest := []any{&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Status, &task.Version, &task.Anchor}
+		if c.match != "" {
+			dest = append(dest, &task.Snippet)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
+		if c.match != "" {
+			task.Snippet = markSnippet(task.Snippet)
+		} else if len(terms) > 0 {
+			task.Snippet = Snippet(task, terms)
+		}
 		tasks = append(tasks, task)
 	}
 
@@ -78,60 +256,323 @@ func Tasks(limit int, search string) ([]*Task, error) {
 	return tasks, nil
 }
 
-func GetTask(id string) (*Task, error) {
-	task := &Task{}
-	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`
-	err := db.QueryRow(query, id).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+func (r *Repository) EachTask(fn func(task *Task) error) error {
+	rows, err := r.q().Query(`SELECT ` + taskColumns + ` FROM scheduler ORDER BY date, id`)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("task not found")
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(task); err != nil {
+			return err
 		}
-		return nil, err
 	}
-	return task, nil
+	return rows.Err()
+}
+
+func (r *Repository) GetTask(id string) (*Task, error) {
+	return getTask(r.q(), id)
 }
 
-func UpdateTask(task *Task) error {
-	query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`
-	res, err := db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+func (r *Repository) AddTask(task *Task) (id int64, err error) {
+	err = r.inTx(func(tx *Repository) error {
+		if id, err = addTask(tx.q(), task); err != nil {
+			return err
+		}
+		after := *task
+		after.ID = strconv.FormatInt(id, 10)
+		return tx.record(ActionInsert, after.ID, nil, &after)
+	})
+	return id, err
+}
+
+// AddTasks добавляет несколько задач в одной транзакции.
+func (r *Repository) AddTasks(tasks []*Task) (ids []int64, err error) {
+	err = r.inTx(func(tx *Repository) error {
+		ids = make([]int64, 0, len(tasks))
+		for _, task := range tasks {
+			id, err := tx.AddTask(task)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// UpdateTask сохраняет поля задачи. Если task.Version задана, она должна
+// совпадать с текущей версией задачи, иначе возвращается ErrConflict.
+// После сохранения task.Version содержит новую версию.
+func (r *Repository) UpdateTask(task *Task) error {
+	after, err := r.change(ActionUpdate, task.ID, task.Version, func(q querier, before *Task) error {
+		return updateTask(q, task, before.EditedAnchor(task), before.Version)
+	})
 	if err != nil {
 		return err
 	}
+	task.Version, task.Anchor = after.Version, after.Anchor
+	return nil
+}
 
-	count, err := res.RowsAffected()
+// RestoreTask записывает все поля задачи, включая статус, одним обновлением
+// без проверки CanTransition. Версия проверяется так же, как в UpdateTask.
+// Если задачи нет, а версия не задана, задача создаётся с идентификатором
+// task.ID, как удалённая задача в RevertTask.
+func (r *Repository) RestoreTask(task *Task) error {
+	var after *Task
+	err := r.inTx(func(tx *Repository) error {
+		q := tx.q()
+		before, err := lockTask(q, task.ID)
+		if err != nil && (err != ErrNotFound || task.Version != 0) {
+			return err
+		}
+
+		snapshot := *task
+		action, version := ActionInsert, int64(0)
+		if before != nil {
+			if task.Version != 0 && task.Version != before.Version {
+				return ErrConflict
+			}
+			action, version = ActionUpdate, before.Version
+		} else if snapshot.Version, err = lastVersion(q, task.ID); err != nil {
+			return err
+		}
+		if err := restoreTask(q, &snapshot, version); err != nil {
+			return err
+		}
+
+		if after, err = getTask(q, task.ID); err != nil {
+			return err
+		}
+		return tx.record(action, task.ID, before, after)
+	})
 	if err != nil {
 		return err
 	}
+	task.Version = after.Version
+	return nil
+}
 
-	if count == 0 {
-		return fmt.Errorf("task not found")
-	}
+// DeleteTask удаляет задачу. Ненулевая version проверяется так же, как в UpdateTask.
+func (r *Repository) DeleteTask(id string, version int64) error {
+	_, err := r.change(ActionDelete, id, version, func(q querier, before *Task) error {
+		return deleteTask(q, id, before.Version)
+	})
+	return err
+}
 
-	return nil
+// UpdateDate переносит задачу на дату next, сохраняя её расписание (см. Task.Anchor).
+func (r *Repository) UpdateDate(next string, id string) error {
+	_, err := r.change(ActionDate, id, 0, func(q querier, before *Task) error {
+		return updateDate(q, next, before.SnoozedAnchor(next), id, before.Version)
+	})
+	return err
 }
 
-func DeleteTask(id string) error {
-	query := `DELETE FROM scheduler WHERE id = ?`
-	res, err := db.Exec(query, id)
-	if err != nil {
-		return err
+// UpdateStatus переводит задачу в новый статус, проверяя допустимость перехода.
+// Для завершения задачи используется CompleteTask.
+func (r *Repository) UpdateStatus(id string, status string) error {
+	_, err := r.change(ActionStatus, id, 0, func(q querier, before *Task) error {
+		if !CanTransition(before.Status, status) {
+			return &TransitionError{From: before.Status, To: status}
+		}
+		return updateStatus(q, id, status, before.Version)
+	})
+	return err
+}
+
+// CompleteTask отмечает задачу выполненной. next вычисляет по прочитанной
+// задаче следующую дату: для повторяющейся задачи она становится новой датой
+// задачи, а пустая строка означает, что задача завершена окончательно.
+// Чтение и запись выполняются в одной транзакции с проверкой версии строки,
+// поэтому параллельные вызовы не сдвигают задачу дважды на одну и ту же дату.
+// Ненулевая version проверяется так же, как в UpdateTask.
+func (r *Repository) CompleteTask(id string, version int64, next func(task *Task) (string, error)) error {
+	_, err := r.change(ActionDone, id, version, func(q querier, before *Task) error {
+		if !CanTransition(before.Status, StatusDone) {
+			return &TransitionError{From: before.Status, To: StatusDone}
+		}
+		date, err := next(before)
+		if err != nil {
+			return err
+		}
+		return completeTask(q, id, date, before.Version)
+	})
+	return err
+}
+
+// RevertTask возвращает задачу к состоянию из ревизии revisionID.
+// Удалённая задача при этом создаётся заново с прежним идентификатором.
+func (r *Repository) RevertTask(id string, revisionID string) (after *Task, err error) {
+	err = r.inTx(func(tx *Repository) error {
+		q := tx.q()
+		rev, err := getRevision(q, revisionID)
+		if err != nil {
+			return err
+		}
+		if rev.TaskID != id {
+			return ErrRevisionNotFound
+		}
+
+		snapshot := rev.After
+		if snapshot == nil {
+			snapshot = rev.Before
+		}
+
+		before, err := lockTask(q, id)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+
+		var version int64
+		if before != nil {
+			version = before.Version
+		} else if snapshot.Version, err = lastVersion(q, id); err != nil {
+			return err
+		}
+		if err := restoreTask(q, snapshot, version); err != nil {
+			return err
+		}
+
+		if after, err = getTask(q, id); err != nil {
+			return err
+		}
+		return tx.record(ActionRevert, id, before, after)
+	})
+	return after, err
+}
+
+// change в транзакции читает задачу id, выполняет над ней изменение fn и
+// записывает в историю состояние до и после. Ненулевая version должна
+// совпадать с текущей версией задачи.
+func (r *Repository) change(action string, id string, version int64,
+	fn func(q querier, before *Task) error) (after *Task, err error) {
+	err = r.inTx(func(tx *Repository) error {
+		q := tx.q()
+		before, err := lockTask(q, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != before.Version {
+			return ErrConflict
+		}
+		if err := fn(q, before); err != nil {
+			return err
+		}
+
+		after = nil
+		if action != ActionDelete {
+			if after, err = getTask(q, id); err != nil {
+				return err
+			}
+		}
+		return tx.record(action, id, before, after)
+	})
+	return after, err
+}
+
+func (r *Repository) record(action string, id string, before, after *Task) error {
+	return addRevision(r.q(), &Revision{
+		TaskID: id,
+		Action: action,
+		Actor:  r.actor,
+		Before: before,
+		After:  after,
+	})
+}
+
+func addTask(q querier, task *Task) (int64, error) {
+	if task.Status == "" {
+		task.Status = StatusTodo
 	}
+	task.Version = 1
+	task.Anchor = ""
+	query := `INSERT INTO scheduler (date, title, comment, repeat, status, version) VALUES (?, ?, ?, ?, ?, ?)`
+	return insert(q, query, task.Date, task.Title, task.Comment, task.Repeat, task.Status, task.Version)
+}
 
-	count, err := res.RowsAffected()
+func getTask(q querier, id string) (*Task, error) {
+	return queryTask(q, `SELECT `+taskColumns+` FROM scheduler WHERE id = ?`, id)
+}
+
+// lockTask читает задачу перед её изменением в транзакции.
+func lockTask(q querier, id string) (*Task, error) {
+	return queryTask(q, forUpdate(q, `SELECT `+taskColumns+` FROM scheduler WHERE id = ?`), id)
+}
+
+func queryTask(q querier, query string, id string) (*Task, error) {
+	task, err := scanTask(q.QueryRow(query, id))
 	if err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
+	return task, nil
+}
 
-	if count == 0 {
-		return fmt.Errorf("task not found")
+// Запросы на изменение задачи выполняются только для той версии, которая
+// была прочитана, и увеличивают её. Так конкурентное изменение не
+// перезапишется молча: транзакция будет повторена (см. Repository.InTx).
+
+func updateTask(q querier, task *Task, anchor string, version int64) error {
+	query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ?, anchor = ?, version = version + 1 WHERE id = ? AND version = ?`
+	return execVersioned(q, query, task.Date, task.Title, task.Comment, task.Repeat, anchor, task.ID, version)
+}
+
+func deleteTask(q querier, id string, version int64) error {
+	return execVersioned(q, `DELETE FROM scheduler WHERE id = ? AND version = ?`, id, version)
+}
+
+func updateDate(q querier, next string, anchor string, id string, version int64) error {
+	query := `UPDATE scheduler SET date = ?, anchor = ?, version = version + 1 WHERE id = ? AND version = ?`
+	return execVersioned(q, query, next, anchor, id, version)
+}
+
+func updateStatus(q querier, id string, status string, version int64) error {
+	query := `UPDATE scheduler SET status = ?, version = version + 1 WHERE id = ? AND version = ?`
+	return execVersioned(q, query, status, id, version)
+}
+
+// completeTask завершает задачу: без next она получает статус "done",
+// иначе переносится на дату next и снова ждёт выполнения. Перенос
+// возвращает задачу в расписание, поэтому Anchor сбрасывается.
+func completeTask(q querier, id string, next string, version int64) error {
+	if next == "" {
+		return updateStatus(q, id, StatusDone, version)
 	}
+	query := `UPDATE scheduler SET date = ?, status = ?, anchor = '', version = version + 1 WHERE id = ? AND version = ?`
+	return execVersioned(q, query, next, StatusTodo, id, version)
+}
 
-	return nil
+// restoreTask записывает снимок задачи целиком. Удалённая задача (version == 0)
+// создаётся заново с прежним идентификатором.
+func restoreTask(q querier, task *Task, version int64) error {
+	if version == 0 {
+		query := `INSERT INTO scheduler (id, date, title, comment, repeat, status, version, anchor) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := q.Exec(query, task.ID, task.Date, task.Title, task.Comment, task.Repeat, task.Status, task.Version, task.Anchor)
+		if _, ok := q.(pgQuerier); ok && err == nil {
+			// Явный id не сдвигает последовательность SERIAL, и следующая
+			// добавленная задача могла бы получить тот же идентификатор.
+			_, err = q.Exec(`SELECT setval(pg_get_serial_sequence('scheduler', 'id'), (SELECT MAX(id) FROM scheduler))`)
+		}
+		return err
+	}
+	query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ?, status = ?, anchor = ?, version = version + 1 WHERE id = ? AND version = ?`
+	return execVersioned(q, query, task.Date, task.Title, task.Comment, task.Repeat, task.Status, task.Anchor, task.ID, version)
 }
 
-func UpdateDate(next string, id string) error {
-	query := `UPDATE scheduler SET date = ? WHERE id = ?`
-	res, err := db.Exec(query, next, id)
+// execVersioned выполняет запрос, который должен затронуть ровно одну задачу.
+// Если строка не изменилась, значит, задачу успели изменить или удалить.
+func execVersioned(q querier, query string, args ...any) error {
+	res, err := q.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -142,8 +583,17 @@ func UpdateDate(next string, id string) error {
 	}
 
 	if count == 0 {
-		return fmt.Errorf("task not found")
+		return errRowChanged
 	}
 
 	return nil
 }
+
+func scanTask(row interface{ Scan(...any) error }) (*Task, error) {
+	task := &Task{}
+	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Status, &task.Version, &task.Anchor)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Template — шаблон с набором задач, которые создаются вместе.
+type Template struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Tasks []*TemplateTask `json:"tasks"`
+}
+
+// TemplateTask — задача шаблона. Offset — сдвиг в днях от даты применения шаблона.
+type TemplateTask struct {
+	Offset  int    `json:"offset"`
+	Title   string `json:"title"`
+	Comment string `json:"comment"`
+	Repeat  string `json:"repeat"`
+}
+
+func (r *Repository) AddTemplate(tpl *Template) (id int64, err error) {
+	err = r.inTx(func(tx *Repository) error {
+		if id, err = insert(tx.q(), `INSERT INTO template (name) VALUES (?)`, tpl.Name); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO template_task (template_id, day_offset, title, comment, repeat) VALUES (?, ?, ?, ?, ?)`
+		for _, t := range tpl.Tasks {
+			if _, err := tx.q().Exec(query, id, t.Offset, t.Title, t.Comment, t.Repeat); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+func (r *Repository) Templates() ([]*Template, error) {
+	rows, err := r.q().Query(`SELECT id, name FROM template ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]*Template, 0)
+	for rows.Next() {
+		tpl := &Template{}
+		if err := rows.Scan(&tpl.ID, &tpl.Name); err != nil {
+			return nil, err
+		}
+		templates = append(templates, tpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, tpl := range templates {
+		if tpl.Tasks, err = templateTasks(r.q(), tpl.ID); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+func (r *Repository) GetTemplate(id string) (*Template, error) {
+	tpl := &Template{}
+	err := r.q().QueryRow(`SELECT id, name FROM template WHERE id = ?`, id).Scan(&tpl.ID, &tpl.Name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("template not found")
+		}
+		return nil, err
+	}
+
+	if tpl.Tasks, err = templateTasks(r.q(), tpl.ID); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+func (r *Repository) DeleteTemplate(id string) error {
+	return r.inTx(func(tx *Repository) error {
+		res, err := tx.q().Exec(`DELETE FROM template WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		count, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return fmt.Errorf("template not found")
+		}
+
+		_, err = tx.q().Exec(`DELETE FROM template_task WHERE template_id = ?`, id)
+		return err
+	})
+}
+
+func templateTasks(q querier, templateID string) ([]*TemplateTask, error) {
+	query := `SELECT day_offset, title, comment, repeat FROM template_task WHERE template_id = ? ORDER BY day_offset, id`
+	rows, err := q.Query(query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]*TemplateTask, 0)
+	for rows.Next() {
+		t := &TemplateTask{}
+		if err := rows.Scan(&t.Offset, &t.Title, &t.Comment, &t.Repeat); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
@@ -0,0 +1,129 @@
+// Package ical читает и формирует календари в формате iCalendar (RFC 5545)
+// и переводит правила повторения задач в RRULE и обратно.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateFormat  = "20060102"
+	stampFormat = "20060102T150405Z"
+
+	// maxLine — максимальная длина строки в октетах без CRLF.
+	maxLine = 75
+)
+
+// Entry — одна запись календаря: задача на весь день.
+type Entry struct {
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time
+	// RRule — правило повторения без префикса "RRULE:", пустое для разовых записей.
+	RRule string
+	// Todo выбирает VTODO вместо VEVENT.
+	Todo bool
+	// Status — значение STATUS, например NEEDS-ACTION или COMPLETED.
+	Status string
+
+	// Override отмечает изменённое повторение другой записи (RECURRENCE-ID).
+	// Заполняется только при чтении.
+	Override bool
+	// Ignored перечисляет прочитанные, но не поддерживаемые свойства записи:
+	// EXDATE, RDATE, второе RRULE, дату в неверном формате.
+	Ignored []string
+}
+
+// Write записывает календарь с записями entries. now используется как DTSTAMP.
+func Write(w io.Writer, name string, entries []Entry, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	stamp := now.UTC().Format(stampFormat)
+
+	line := func(s string) {
+		writeFolded(bw, s)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//TaskManager//Scheduler//RU")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if name != "" {
+		line("X-WR-CALNAME:" + escape(name))
+	}
+
+	for _, e := range entries {
+		component := "VEVENT"
+		if e.Todo {
+			component = "VTODO"
+		}
+		date := e.Date.Format(dateFormat)
+
+		line("BEGIN:" + component)
+		line("UID:" + escape(e.UID))
+		line("DTSTAMP:" + stamp)
+		if e.Todo {
+			// DUE должен быть позже DTSTART, поэтому у задачи только одно из них:
+			// срок DUE, а у повторяющейся — DTSTART, от которого считается RRULE.
+			if e.RRule != "" {
+				line("DTSTART;VALUE=DATE:" + date)
+			} else {
+				line("DUE;VALUE=DATE:" + date)
+			}
+			if e.Status != "" {
+				line("STATUS:" + e.Status)
+			}
+		} else {
+			line("DTSTART;VALUE=DATE:" + date)
+			line("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format(dateFormat))
+			line("TRANSP:TRANSPARENT")
+		}
+		line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.RRule != "" {
+			line("RRULE:" + e.RRule)
+		}
+		line("END:" + component)
+	}
+
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeFolded записывает строку содержимого, разбивая её на части не длиннее
+// 75 октетов. Разрез никогда не приходится на середину символа UTF-8.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := maxLine
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Продолжение начинается с пробела, который тоже занимает октет.
+		limit = maxLine - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// escape экранирует текстовое значение по правилам RFC 5545.
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
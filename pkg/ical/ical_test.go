@@ -0,0 +1,221 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRRule(t *testing.T) {
+	date := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		repeat string
+		rule   string
+		ok     bool
+	}{
+		{"d 1", "FREQ=DAILY", true},
+		{"d 7", "FREQ=DAILY;INTERVAL=7", true},
+		{"y", "FREQ=YEARLY", true},
+		{"w 1,3,7", "FREQ=WEEKLY;BYDAY=MO,WE,SU", true},
+		{"m 1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1", true},
+		{"m 15 1,6", "FREQ=MONTHLY;BYMONTHDAY=15;BYMONTH=1,6", true},
+		{"", "", false},
+		{"d 0", "", false},
+		{"w 8", "", false},
+		{"m 0", "", false},
+		{"x 1", "", false},
+	}
+	for _, v := range tbl {
+		rule, ok := RRule(v.repeat, date)
+		assert.Equal(t, v.ok, ok, v.repeat)
+		assert.Equal(t, v.rule, rule, v.repeat)
+	}
+
+	_, ok := RRule("y", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC))
+	assert.False(t, ok, "29 февраля переносится иначе, чем в RRULE")
+}
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+	entries := []Entry{
+		{
+			UID:         "task-1@taskmanager",
+			Summary:     "Отчёт; квартал, итоги",
+			Description: "Первая строка\nвторая строка",
+			Date:        time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC),
+			RRule:       "FREQ=WEEKLY;BYDAY=TH",
+		},
+		{
+			UID:     "task-2@taskmanager",
+			Summary: strings.Repeat("Очень длинное название ", 10),
+			Date:    time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+			Todo:    true,
+			Status:  "NEEDS-ACTION",
+		},
+		{
+			UID:     "task-3@taskmanager",
+			Summary: "Полить цветы",
+			Date:    time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC),
+			RRule:   "FREQ=DAILY;INTERVAL=3",
+			Todo:    true,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "Задачи", entries, now))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	assert.Contains(t, out, "X-WR-CALNAME:Задачи\r\n")
+	assert.Contains(t, out, "DTSTAMP:20260310T123000Z\r\n")
+
+	assert.Contains(t, out, "BEGIN:VEVENT\r\nUID:task-1@taskmanager\r\n")
+	assert.Contains(t, out, "DTSTART;VALUE=DATE:20260312\r\nDTEND;VALUE=DATE:20260313\r\n")
+	assert.Contains(t, out, `SUMMARY:Отчёт\; квартал\, итоги`+"\r\n")
+	assert.Contains(t, out, `DESCRIPTION:Первая строка\nвторая строка`+"\r\n")
+	assert.Contains(t, out, "RRULE:FREQ=WEEKLY;BYDAY=TH\r\n")
+
+	// У задачи нет DUE, совпадающего с DTSTART: разовая получает только
+	// срок, повторяющаяся — только начало для RRULE.
+	assert.Contains(t, out, "BEGIN:VTODO\r\nUID:task-2@taskmanager\r\nDTSTAMP:20260310T123000Z\r\nDUE;VALUE=DATE:20260331\r\n")
+	assert.Contains(t, out, "STATUS:NEEDS-ACTION\r\n")
+	assert.Contains(t, out, "BEGIN:VTODO\r\nUID:task-3@taskmanager\r\nDTSTAMP:20260310T123000Z\r\nDTSTART;VALUE=DATE:20260314\r\n")
+	assert.NotContains(t, out, "DTSTART;VALUE=DATE:20260331")
+	assert.NotContains(t, out, "DUE;VALUE=DATE:20260314")
+
+	parsed, err := Parse(strings.NewReader(out))
+	require.NoError(t, err)
+	require.Len(t, parsed, len(entries))
+	for i, e := range entries {
+		assert.Equal(t, e.Date, parsed[i].Date, e.UID)
+		assert.Equal(t, e.RRule, parsed[i].RRule, e.UID)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, line)
+		assert.NotContains(t, line, "\n")
+		assert.True(t, strings.ToValidUTF8(line, "?") == line, "строка разрезана посреди символа: %q", line)
+	}
+
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	assert.Contains(t, unfolded, "SUMMARY:"+strings.Repeat("Очень длинное название ", 10)+"\r\n")
+}
+
+func TestRepeat(t *testing.T) {
+	// 10 марта 2026 года — вторник.
+	date := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		rrule  string
+		repeat string
+		lost   []string
+	}{
+		{"FREQ=DAILY", "d 1", nil},
+		{"FREQ=DAILY;INTERVAL=3;COUNT=10", "d 3", []string{"COUNT=10"}},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", "w 1,2,3,4,5", nil},
+		{"FREQ=WEEKLY", "w 2", nil},
+		{"FREQ=WEEKLY;BYDAY=FR,MO", "w 1,5", nil},
+		{"FREQ=WEEKLY;INTERVAL=2", "d 14", nil},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", "w 1,4", []string{"INTERVAL=2"}},
+		{"FREQ=MONTHLY", "m 10", nil},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1;UNTIL=20270101T000000Z", "m 1,-1", []string{"UNTIL=20270101T000000Z"}},
+		{"FREQ=MONTHLY;INTERVAL=3", "m 10", []string{"INTERVAL=3"}},
+		{"FREQ=YEARLY", "y", nil},
+		{"FREQ=YEARLY;BYMONTH=6", "m 10 6", nil},
+		{"FREQ=YEARLY;BYMONTH=1,7;BYMONTHDAY=15", "m 15 1,7", nil},
+		{"FREQ=HOURLY;INTERVAL=4", "d 1", []string{"FREQ=HOURLY"}},
+	}
+	for _, v := range tbl {
+		repeat, lost, err := Repeat(v.rrule, date)
+		require.NoError(t, err, v.rrule)
+		assert.Equal(t, v.repeat, repeat, v.rrule)
+		assert.Equal(t, v.lost, lost, v.rrule)
+	}
+
+	for _, rrule := range []string{
+		"FREQ=MONTHLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYSETPOS=-1;BYDAY=FR",
+		"FREQ=DAILY;INTERVAL=500",
+		"FREQ=FORTNIGHTLY",
+		"INTERVAL",
+	} {
+		_, _, err := Repeat(rrule, date)
+		assert.Error(t, err, rrule)
+	}
+
+	// Правило, записанное через RRule, переводится обратно без потерь.
+	for _, repeat := range []string{"d 5", "y", "w 1,3,7", "m 1,-1", "m 15 1,6"} {
+		rule, ok := RRule(repeat, date)
+		require.True(t, ok, repeat)
+		back, lost, err := Repeat(rule, date)
+		require.NoError(t, err, repeat)
+		assert.Equal(t, repeat, back)
+		assert.Empty(t, lost)
+	}
+}
+
+func TestParse(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Moscow\r\nBEGIN:STANDARD\r\nDTSTART:19700101T000000\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:ev-1@example.com\r\n" +
+		"DTSTART;TZID=Europe/Moscow:20260312T090000\r\n" +
+		"SUMMARY:Отчёт\\; квартал\\, ито\r\n ги\r\n" +
+		"DESCRIPTION:строка\\nвторая\r\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=TH\r\n" +
+		"EXDATE:20260319T090000\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Напоминание\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:ev-1@example.com\r\n" +
+		"RECURRENCE-ID:20260326T090000\r\n" +
+		"DTSTART:20260327T090000\r\n" +
+		"SUMMARY:Перенесённый отчёт\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:todo-1\r\n" +
+		"DTSTART;VALUE=DATE:20260301\r\n" +
+		"DUE;VALUE=DATE:20260305\r\n" +
+		"SUMMARY:Сдать книги\r\n" +
+		"COMPLETED:20260304T100000Z\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	entries, err := Parse(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	ev := entries[0]
+	assert.Equal(t, "ev-1@example.com", ev.UID)
+	assert.Equal(t, "Отчёт; квартал, итоги", ev.Summary)
+	assert.Equal(t, "строка\nвторая", ev.Description)
+	assert.Equal(t, "20260312", ev.Date.Format(dateFormat))
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=TH", ev.RRule)
+	assert.Equal(t, []string{"EXDATE"}, ev.Ignored)
+	assert.False(t, ev.Todo)
+
+	assert.True(t, entries[1].Override)
+
+	todo := entries[2]
+	assert.True(t, todo.Todo)
+	assert.Equal(t, "20260305", todo.Date.Format(dateFormat))
+	assert.Equal(t, "COMPLETED", todo.Status)
+
+	// То, что записал Write, читается обратно.
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "", []Entry{{UID: "x", Summary: strings.Repeat("длинно, ", 20), Date: ev.Date}}, time.Now()))
+	entries, err = Parse(&buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, strings.Repeat("длинно, ", 20), entries[0].Summary)
+
+	_, err = Parse(strings.NewReader("hello"))
+	assert.ErrorIs(t, err, ErrNotCalendar)
+	_, err = Parse(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n"))
+	assert.Error(t, err)
+}
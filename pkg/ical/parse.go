@@ -0,0 +1,196 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotCalendar возвращается, если данные не начинаются с BEGIN:VCALENDAR.
+var ErrNotCalendar = errors.New("not an iCalendar file")
+
+// Parse читает записи VEVENT и VTODO календаря. Вложенные компоненты
+// (напоминания VALARM, часовые пояса) пропускаются. От даты с временем
+// остаётся только дата в том виде, в каком она записана в файле.
+func Parse(r io.Reader) ([]Entry, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0].text, "BEGIN:VCALENDAR") {
+		return nil, ErrNotCalendar
+	}
+
+	var (
+		entries []Entry
+		stack   []string
+		entry   *Entry
+		start   time.Time
+		due     time.Time
+	)
+	for _, l := range lines {
+		name, value, ok := splitLine(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid content line", l.num)
+		}
+
+		switch name {
+		case "BEGIN":
+			component := strings.ToUpper(value)
+			if len(stack) == 1 && (component == "VEVENT" || component == "VTODO") {
+				entry = &Entry{Todo: component == "VTODO"}
+				start, due = time.Time{}, time.Time{}
+			}
+			stack = append(stack, component)
+			continue
+		case "END":
+			component := strings.ToUpper(value)
+			if len(stack) == 0 || stack[len(stack)-1] != component {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", l.num, value)
+			}
+			stack = stack[:len(stack)-1]
+			if entry != nil && len(stack) == 1 {
+				entry.Date = start
+				if entry.Todo && !due.IsZero() {
+					entry.Date = due
+				}
+				entries = append(entries, *entry)
+				entry = nil
+			}
+			continue
+		}
+
+		// Свойства вложенных компонентов и самого календаря не нужны.
+		if entry == nil || len(stack) != 2 {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			entry.UID = value
+		case "SUMMARY":
+			entry.Summary = unescape(value)
+		case "DESCRIPTION":
+			entry.Description = unescape(value)
+		case "STATUS":
+			entry.Status = strings.ToUpper(value)
+		case "COMPLETED":
+			if entry.Status == "" {
+				entry.Status = "COMPLETED"
+			}
+		case "RECURRENCE-ID":
+			entry.Override = true
+		case "DTSTART", "DUE":
+			date, err := parseDate(value)
+			if err != nil {
+				entry.Ignored = append(entry.Ignored, name)
+				continue
+			}
+			if name == "DUE" {
+				due = date
+			} else {
+				start = date
+			}
+		case "RRULE":
+			if entry.RRule != "" {
+				entry.Ignored = append(entry.Ignored, name)
+				continue
+			}
+			entry.RRule = value
+		case "EXDATE", "RDATE", "EXRULE":
+			entry.Ignored = append(entry.Ignored, name)
+		}
+	}
+
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1])
+	}
+	return entries, nil
+}
+
+type contentLine struct {
+	num  int
+	text string
+}
+
+// unfold склеивает перенесённые строки: продолжение начинается с пробела
+// или табуляции. Пустые строки пропускаются.
+func unfold(r io.Reader) ([]contentLine, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var lines []contentLine
+	num := 0
+	for sc.Scan() {
+		num++
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if num == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, contentLine{num: num, text: text})
+	}
+	return lines, sc.Err()
+}
+
+// splitLine разбирает строку вида NAME;PARAM=VALUE:значение. Параметры
+// не нужны и отбрасываются; двоеточие внутри кавычек не считается разделителем.
+func splitLine(line string) (name, value string, ok bool) {
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon <= 0 {
+		return "", "", false
+	}
+
+	name, _, _ = strings.Cut(line[:colon], ";")
+	return strings.ToUpper(name), line[colon+1:], true
+}
+
+// parseDate берёт дату из значения DATE или DATE-TIME.
+func parseDate(value string) (time.Time, error) {
+	if len(value) < len(dateFormat) {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return time.Parse(dateFormat, value[:len(dateFormat)])
+}
+
+// unescape снимает экранирование текстового значения.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,227 @@
+package ical
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRule переводит правило повторения задачи в RRULE. Второе значение
+// равно false, если у правила нет точного аналога и повторы нужно
+// развернуть по датам.
+func RRule(repeat string, date time.Time) (string, bool) {
+	parts := strings.Fields(repeat)
+	if len(parts) == 0 {
+		return "", false
+	}
+
+	switch parts[0] {
+	case "d":
+		if len(parts) != 2 {
+			return "", false
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 1 || n > 400 {
+			return "", false
+		}
+		if n == 1 {
+			return "FREQ=DAILY", true
+		}
+		return fmt.Sprintf("FREQ=DAILY;INTERVAL=%d", n), true
+	case "y":
+		// Задача от 29 февраля в невисокосные годы переносится на 1 марта,
+		// а RRULE такие годы пропускает.
+		if len(parts) != 1 || (date.Month() == time.February && date.Day() == 29) {
+			return "", false
+		}
+		return "FREQ=YEARLY", true
+	case "w":
+		if len(parts) != 2 {
+			return "", false
+		}
+		days, ok := numbers(parts[1], 1, 7)
+		if !ok {
+			return "", false
+		}
+		names := make([]string, len(days))
+		for i, d := range days {
+			names[i] = weekdays[d-1]
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(names, ","), true
+	case "m":
+		if len(parts) < 2 || len(parts) > 3 {
+			return "", false
+		}
+		days, ok := numbers(parts[1], -31, 31)
+		if !ok {
+			return "", false
+		}
+		rule := "FREQ=MONTHLY;BYMONTHDAY=" + join(days)
+		if len(parts) == 3 {
+			months, ok := numbers(parts[2], 1, 12)
+			if !ok {
+				return "", false
+			}
+			rule += ";BYMONTH=" + join(months)
+		}
+		return rule, true
+	}
+	return "", false
+}
+
+var weekdays = [7]string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// numbers разбирает список чисел через запятую из диапазона [lo, hi] без нуля.
+func numbers(s string, lo, hi int) ([]int, bool) {
+	var res []int
+	for _, p := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n == 0 || n < lo || n > hi {
+			return nil, false
+		}
+		res = append(res, n)
+	}
+	return res, true
+}
+
+func join(nums []int) string {
+	s := make([]string, len(nums))
+	for i, n := range nums {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// Repeat переводит RRULE в ближайшее правило повторения задачи для записи
+// с датой date. lost перечисляет части RRULE, которые пришлось отбросить
+// (COUNT, UNTIL, интервал, который правило задачи не выражает). Ошибка
+// означает, что правило не удалось передать даже приближённо.
+func Repeat(rrule string, date time.Time) (repeat string, lost []string, err error) {
+	parts := make(map[string]string)
+	for _, p := range strings.Split(rrule, ";") {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid RRULE part %q", p)
+		}
+		parts[strings.ToUpper(k)] = strings.ToUpper(v)
+	}
+
+	interval := 1
+	if v, ok := parts["INTERVAL"]; ok {
+		if interval, err = strconv.Atoi(v); err != nil || interval < 1 {
+			return "", nil, fmt.Errorf("invalid INTERVAL %q", v)
+		}
+	}
+	for _, k := range []string{"COUNT", "UNTIL"} {
+		if v, ok := parts[k]; ok {
+			lost = append(lost, k+"="+v)
+		}
+	}
+	for _, k := range []string{"BYSETPOS", "BYYEARDAY", "BYWEEKNO", "BYHOUR", "BYMINUTE", "BYSECOND"} {
+		if _, ok := parts[k]; ok {
+			return "", nil, fmt.Errorf("%s is not supported", k)
+		}
+	}
+
+	weekdays, err := byDay(parts["BYDAY"])
+	if err != nil {
+		return "", nil, err
+	}
+	freq := parts["FREQ"]
+	if len(weekdays) > 0 && freq != "DAILY" && freq != "WEEKLY" {
+		return "", nil, fmt.Errorf("BYDAY=%s is not supported with FREQ=%s", parts["BYDAY"], freq)
+	}
+	monthDays, months := parts["BYMONTHDAY"], parts["BYMONTH"]
+	if monthDays != "" {
+		if _, ok := numbers(monthDays, -31, 31); !ok {
+			return "", nil, fmt.Errorf("invalid BYMONTHDAY %q", monthDays)
+		}
+	}
+	if months != "" {
+		if _, ok := numbers(months, 1, 12); !ok {
+			return "", nil, fmt.Errorf("invalid BYMONTH %q", months)
+		}
+	}
+	if freq != "MONTHLY" && freq != "YEARLY" && (monthDays != "" || months != "") {
+		return "", nil, fmt.Errorf("BYMONTHDAY and BYMONTH are not supported with FREQ=%s", freq)
+	}
+	loseInterval := func() {
+		if interval > 1 {
+			lost = append(lost, "INTERVAL="+strconv.Itoa(interval))
+		}
+	}
+
+	switch freq {
+	case "SECONDLY", "MINUTELY", "HOURLY":
+		lost = append(lost, "FREQ="+freq)
+		return "d 1", lost, nil
+	case "DAILY":
+		if len(weekdays) > 0 {
+			loseInterval()
+			return "w " + join(weekdays), lost, nil
+		}
+		if interval > 400 {
+			return "", nil, fmt.Errorf("INTERVAL=%d is too large", interval)
+		}
+		return "d " + strconv.Itoa(interval), lost, nil
+	case "WEEKLY":
+		if len(weekdays) == 0 {
+			weekdays = []int{isoWeekday(date)}
+		}
+		// Раз в несколько недель в один день — то же, что раз в 7·N дней.
+		if interval > 1 && len(weekdays) == 1 && weekdays[0] == isoWeekday(date) && interval*7 <= 400 {
+			return "d " + strconv.Itoa(interval*7), lost, nil
+		}
+		loseInterval()
+		return "w " + join(weekdays), lost, nil
+	case "MONTHLY":
+		loseInterval()
+		if monthDays == "" {
+			monthDays = strconv.Itoa(date.Day())
+		}
+		if months != "" {
+			return "m " + monthDays + " " + months, lost, nil
+		}
+		return "m " + monthDays, lost, nil
+	case "YEARLY":
+		loseInterval()
+		if monthDays == "" && months == "" {
+			return "y", lost, nil
+		}
+		if monthDays == "" {
+			monthDays = strconv.Itoa(date.Day())
+		}
+		if months == "" {
+			months = strconv.Itoa(int(date.Month()))
+		}
+		return "m " + monthDays + " " + months, lost, nil
+	}
+	return "", nil, fmt.Errorf("unsupported FREQ %q", freq)
+}
+
+// byDay разбирает BYDAY в номера дней недели (1 — понедельник).
+// Порядковые номера вроде 1MO или -1FR правило задачи не выражает.
+func byDay(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var days []int
+	for _, d := range strings.Split(value, ",") {
+		n := slices.Index(weekdays[:], d)
+		if n < 0 {
+			return nil, fmt.Errorf("BYDAY=%s is not supported", value)
+		}
+		days = append(days, n+1)
+	}
+	slices.Sort(days)
+	return slices.Compact(days), nil
+}
+
+func isoWeekday(date time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(date.Weekday())
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"TaskManager/pkg/api"
+	"go1f/pkg/db"
 	"log"
 	"net/http"
 	"os"
@@ -13,20 +14,16 @@ const (
 	DbFile      = "scheduler.db"
 )
 
-func StartServer() {
-	api.Init()
-
+func StartServer(store db.Store) error {
 	port := os.Getenv("TODO_PORT")
 	if port == "" {
 		port = DefaultPort
 	}
 
-	http.Handle("/", http.FileServer(http.Dir(WebDir)))
-	http.HandleFunc("/api/signin", api.SignInHandler)
-	http.HandleFunc("/api/task", api.Auth(api.TaskHandler))
-	http.HandleFunc("/api/task/done", api.Auth(api.DoneTaskHandler))
-	http.HandleFunc("/api/tasks", api.Auth(api.TasksHandler))
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(WebDir)))
+	api.New(store).Register(mux)
 
 	log.Printf("Сервер запущен на http://localhost:%s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	return http.ListenAndServe(":"+port, mux)
 }
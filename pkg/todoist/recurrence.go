@@ -0,0 +1,219 @@
+package todoist
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]int{
+	"monday": 1, "mon": 1, "понедельник": 1, "пн": 1,
+	"tuesday": 2, "tue": 2, "tues": 2, "вторник": 2, "вт": 2,
+	"wednesday": 3, "wed": 3, "среда": 3, "среду": 3, "ср": 3,
+	"thursday": 4, "thu": 4, "thur": 4, "thurs": 4, "четверг": 4, "чт": 4,
+	"friday": 5, "fri": 5, "пятница": 5, "пятницу": 5, "пт": 5,
+	"saturday": 6, "sat": 6, "суббота": 6, "субботу": 6, "сб": 6,
+	"sunday": 7, "sun": 7, "воскресенье": 7, "вс": 7,
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "january": 1, "января": 1, "янв": 1,
+	"feb": 2, "february": 2, "февраля": 2, "фев": 2,
+	"mar": 3, "march": 3, "марта": 3, "мар": 3,
+	"apr": 4, "april": 4, "апреля": 4, "апр": 4,
+	"may": 5, "мая": 5,
+	"jun": 6, "june": 6, "июня": 6, "июн": 6,
+	"jul": 7, "july": 7, "июля": 7, "июл": 7,
+	"aug": 8, "august": 8, "августа": 8, "авг": 8,
+	"sep": 9, "sept": 9, "september": 9, "сентября": 9, "сен": 9,
+	"oct": 10, "october": 10, "октября": 10, "окт": 10,
+	"nov": 11, "november": 11, "ноября": 11, "ноя": 11,
+	"dec": 12, "december": 12, "декабря": 12, "дек": 12,
+}
+
+// units — единицы повторения и их формы в английском и русском.
+var units = map[string]string{
+	"day": "day", "days": "day", "день": "day", "дня": "day", "дней": "day",
+	"week": "week", "weeks": "week", "неделю": "week", "недели": "week", "недель": "week",
+	"month": "month", "months": "month", "месяц": "month", "месяца": "month", "месяцев": "month",
+	"year": "year", "years": "year", "год": "year", "года": "year", "лет": "year",
+}
+
+// Repeat переводит строку повторения Todoist в ближайшее правило повторения
+// задачи с датой date. lost перечисляет то, что пришлось отбросить:
+// интервал, который правило не выражает, время, начало и конец повторов.
+func Repeat(recurrence string, date time.Time) (repeat string, lost []string, err error) {
+	s := strings.ToLower(strings.TrimSpace(recurrence))
+	for _, sep := range []string{" starting ", " from ", " until ", " ending ", " for ", " с ", " до "} {
+		if i := strings.Index(s, sep); i > 0 {
+			lost = append(lost, strings.TrimSpace(s[i:]))
+			s = s[:i]
+		}
+	}
+	// Время («at 9am», «в 10:00») отбрасывается, «в понедельник» — нет.
+	for _, sep := range []string{" at ", " в "} {
+		if i := strings.Index(s, sep); i > 0 && i+len(sep) < len(s) && s[i+len(sep)] >= '0' && s[i+len(sep)] <= '9' {
+			lost = append(lost, strings.TrimSpace(s[i:]))
+			s = s[:i]
+		}
+	}
+
+	words := strings.Fields(strings.NewReplacer(",", " ", " and ", " ", " и ", " ").Replace(s))
+	if len(words) == 0 {
+		return "", nil, fmt.Errorf("unsupported recurrence %q", recurrence)
+	}
+	switch words[0] {
+	case "daily", "ежедневно":
+		return "d 1", lost, nil
+	case "weekly", "еженедельно":
+		return "w " + strconv.Itoa(isoWeekday(date)), lost, nil
+	case "monthly", "ежемесячно":
+		return "m " + strconv.Itoa(date.Day()), lost, nil
+	case "yearly", "annually", "ежегодно":
+		return "y", lost, nil
+	case "every", "every!", "ev", "ev!", "каждый", "каждую", "каждое", "каждые", "каждого":
+		words = words[1:]
+	default:
+		return "", nil, fmt.Errorf("unsupported recurrence %q", recurrence)
+	}
+
+	n := 1
+	if len(words) > 0 {
+		if v, err := strconv.Atoi(words[0]); err == nil && len(words) > 1 && units[words[1]] != "" {
+			n, words = v, words[1:]
+		} else if words[0] == "other" || words[0] == "второй" || words[0] == "вторую" {
+			n, words = 2, words[1:]
+		}
+	}
+	words = slices.DeleteFunc(words, func(w string) bool {
+		return w == "on" || w == "в" || w == "во" || w == "по"
+	})
+	// «every week on monday», «every month on the 15th»: единица перед
+	// списком дней ничего не добавляет.
+	if len(words) > 1 && (units[words[0]] == "week" || units[words[0]] == "month") {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return "", nil, fmt.Errorf("unsupported recurrence %q", recurrence)
+	}
+	if n < 1 {
+		return "", nil, fmt.Errorf("invalid interval in %q", recurrence)
+	}
+	interval := func() {
+		if n > 1 {
+			lost = append(lost, "interval "+strconv.Itoa(n))
+		}
+	}
+
+	if len(words) == 1 {
+		switch units[words[0]] {
+		case "day":
+			if n > 400 {
+				return "", nil, fmt.Errorf("interval %d is too large", n)
+			}
+			return "d " + strconv.Itoa(n), lost, nil
+		case "week":
+			if n > 1 && 7*n <= 400 {
+				return "d " + strconv.Itoa(7*n), lost, nil
+			}
+			return "w " + strconv.Itoa(isoWeekday(date)), lost, nil
+		case "month":
+			interval()
+			return "m " + strconv.Itoa(date.Day()), lost, nil
+		case "year":
+			interval()
+			return "y", lost, nil
+		}
+	}
+
+	switch strings.Join(words, " ") {
+	case "weekday", "workday", "будний день", "рабочий день":
+		interval()
+		return "w 1,2,3,4,5", lost, nil
+	case "weekend", "выходной", "выходные":
+		interval()
+		return "w 6,7", lost, nil
+	case "last day", "последний день", "последнее число":
+		interval()
+		return "m -1", lost, nil
+	}
+
+	if days, ok := weekdaysOf(words); ok {
+		if n > 1 && len(days) == 1 && days[0] == isoWeekday(date) && 7*n <= 400 {
+			return "d " + strconv.Itoa(7*n), lost, nil
+		}
+		interval()
+		return "w " + join(days), lost, nil
+	}
+	if days, month, ok := monthDaysOf(words); ok {
+		interval()
+		if month > 0 {
+			return fmt.Sprintf("m %s %d", join(days), month), lost, nil
+		}
+		return "m " + join(days), lost, nil
+	}
+	return "", nil, fmt.Errorf("unsupported recurrence %q", recurrence)
+}
+
+// weekdaysOf разбирает список дней недели: «mon wed fri».
+func weekdaysOf(words []string) ([]int, bool) {
+	var days []int
+	for _, w := range words {
+		d, ok := weekdayNames[w]
+		if !ok {
+			return nil, false
+		}
+		days = append(days, d)
+	}
+	slices.Sort(days)
+	return slices.Compact(days), true
+}
+
+// monthDaysOf разбирает числа месяца: «1st 15th», «15 числа», «jan 15»,
+// «15 января», «last day». month равен нулю, если месяц не указан.
+func monthDaysOf(words []string) (days []int, month int, ok bool) {
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if m, found := monthNames[w]; found && month == 0 {
+			month = m
+			continue
+		}
+		if w == "числа" || w == "число" || w == "day" || w == "of" || w == "the" {
+			continue
+		}
+		if w == "last" || w == "последний" || w == "последнее" {
+			days = append(days, -1)
+			continue
+		}
+		for _, suffix := range []string{"st", "nd", "rd", "th", "-го", "-е"} {
+			w = strings.TrimSuffix(w, suffix)
+		}
+		d, err := strconv.Atoi(w)
+		if err != nil || d < 1 || d > 31 {
+			return nil, 0, false
+		}
+		days = append(days, d)
+	}
+	if len(days) == 0 {
+		return nil, 0, false
+	}
+	slices.Sort(days)
+	return slices.Compact(days), month, true
+}
+
+func isoWeekday(date time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(date.Weekday())
+}
+
+func join(nums []int) string {
+	s := make([]string, len(nums))
+	for i, n := range nums {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,153 @@
+// Package todoist читает выгрузку задач Todoist в JSON и переводит
+// строки повторения Todoist («every monday», «каждые 3 дня») в правила
+// повторения задач.
+package todoist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const isoFormat = "2006-01-02"
+
+// Item — задача Todoist. Подзадачи собраны в Children родителя.
+type Item struct {
+	ID          string
+	Content     string
+	Description string
+	Project     string
+	Labels      []string
+	Completed   bool
+	// Date — срок задачи; нулевой, если срок не задан.
+	Date time.Time
+	// Recurrence — строка повторения, например «every monday».
+	Recurrence string
+	Children   []*Item
+}
+
+// id принимает идентификатор и строкой, и числом: старые версии API
+// выгружали числа.
+type id string
+
+func (i *id) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*i = id(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid id %s", data)
+	}
+	*i = id(n)
+	return nil
+}
+
+type rawItem struct {
+	ID          id       `json:"id"`
+	Content     string   `json:"content"`
+	Description string   `json:"description"`
+	ProjectID   id       `json:"project_id"`
+	ParentID    id       `json:"parent_id"`
+	Labels      []string `json:"labels"`
+	Checked     bool     `json:"checked"`
+	IsCompleted bool     `json:"is_completed"`
+	CompletedAt *string  `json:"completed_at"`
+	Due         *struct {
+		Date        string `json:"date"`
+		String      string `json:"string"`
+		IsRecurring bool   `json:"is_recurring"`
+	} `json:"due"`
+}
+
+type rawProject struct {
+	ID           id     `json:"id"`
+	Name         string `json:"name"`
+	InboxProject bool   `json:"inbox_project"`
+	IsInbox      bool   `json:"is_inbox_project"`
+}
+
+// Parse читает выгрузку Todoist: ответ Sync API ({"items": [...],
+// "projects": [...]}), ответ REST API со списком задач или {"results": [...]}.
+// Проект «Входящие» не указывается.
+func Parse(r io.Reader) ([]*Item, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var export struct {
+		Items    *[]rawItem   `json:"items"`
+		Results  *[]rawItem   `json:"results"`
+		Projects []rawProject `json:"projects"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &export.Items)
+	} else {
+		err = json.Unmarshal(data, &export)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading Todoist JSON: %v", err)
+	}
+	if export.Items == nil && export.Results == nil {
+		return nil, errors.New("not a Todoist export: no items")
+	}
+	var raws []rawItem
+	for _, list := range []*[]rawItem{export.Items, export.Results} {
+		if list != nil {
+			raws = append(raws, *list...)
+		}
+	}
+
+	projects := make(map[id]string)
+	for _, p := range export.Projects {
+		if !p.InboxProject && !p.IsInbox && p.Name != "Inbox" {
+			projects[p.ID] = p.Name
+		}
+	}
+
+	items := make([]*Item, 0, len(raws))
+	byID := make(map[id]*Item, len(raws))
+	for _, raw := range raws {
+		item := &Item{
+			ID:          string(raw.ID),
+			Content:     raw.Content,
+			Description: raw.Description,
+			Project:     projects[raw.ProjectID],
+			Labels:      raw.Labels,
+			Completed:   raw.Checked || raw.IsCompleted || raw.CompletedAt != nil,
+		}
+		if raw.Due != nil && raw.Due.Date != "" {
+			if len(raw.Due.Date) < len(isoFormat) {
+				return nil, fmt.Errorf("item %s: invalid due date %q", raw.ID, raw.Due.Date)
+			}
+			if item.Date, err = time.Parse(isoFormat, raw.Due.Date[:len(isoFormat)]); err != nil {
+				return nil, fmt.Errorf("item %s: invalid due date %q", raw.ID, raw.Due.Date)
+			}
+			if raw.Due.IsRecurring {
+				item.Recurrence = raw.Due.String
+			}
+		}
+		items = append(items, item)
+		byID[raw.ID] = item
+	}
+
+	// Подзадачи переходят в родителя, если он есть в выгрузке.
+	var roots []*Item
+	for i, raw := range raws {
+		parent, ok := byID[raw.ParentID]
+		if raw.ParentID != "" && ok && parent != items[i] {
+			parent.Children = append(parent.Children, items[i])
+			continue
+		}
+		roots = append(roots, items[i])
+	}
+	return roots, nil
+}
@@ -0,0 +1,101 @@
+package todoist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepeat(t *testing.T) {
+	// 14 октября 2026 года — среда.
+	date := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		recurrence string
+		repeat     string
+		lost       []string
+	}{
+		{"every day", "d 1", nil},
+		{"daily", "d 1", nil},
+		{"every 3 days", "d 3", nil},
+		{"every other day", "d 2", nil},
+		{"every week", "w 3", nil},
+		{"every 2 weeks", "d 14", nil},
+		{"every mon, wed and fri", "w 1,3,5", nil},
+		{"every week on monday", "w 1", nil},
+		{"every other wednesday", "d 14", nil},
+		{"every weekday", "w 1,2,3,4,5", nil},
+		{"every! month", "m 14", nil},
+		{"every 1st, 15th", "m 1,15", nil},
+		{"every month on the last day", "m -1", nil},
+		{"every 3 months", "m 14", []string{"interval 3"}},
+		{"every jan 15", "m 15 1", nil},
+		{"every year", "y", nil},
+		{"every day at 9am", "d 1", []string{"at 9am"}},
+		{"every monday until dec 31", "w 1", []string{"until dec 31"}},
+		{"каждый день", "d 1", nil},
+		{"каждые 3 дня", "d 3", nil},
+		{"каждую неделю в понедельник", "w 1", nil},
+		{"каждый пн, чт", "w 1,4", nil},
+		{"каждый будний день в 10:00", "w 1,2,3,4,5", []string{"в 10:00"}},
+		{"каждое 15 число", "m 15", nil},
+		{"каждые 15 января", "m 15 1", nil},
+		{"ежегодно", "y", nil},
+	}
+	for _, v := range tbl {
+		repeat, lost, err := Repeat(v.recurrence, date)
+		require.NoError(t, err, v.recurrence)
+		assert.Equal(t, v.repeat, repeat, v.recurrence)
+		assert.Equal(t, v.lost, lost, v.recurrence)
+	}
+
+	for _, s := range []string{"", "every", "after 3 days", "every first monday", "every 500 days", "every 0 days"} {
+		_, _, err := Repeat(s, date)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParse(t *testing.T) {
+	sync := `{
+		"projects": [
+			{"id": "1", "name": "Inbox", "inbox_project": true},
+			{"id": "2", "name": "Дом"}
+		],
+		"items": [
+			{"id": "10", "content": "Уборка", "project_id": "2", "labels": ["выходные"],
+			 "due": {"date": "2026-10-17", "string": "every saturday", "is_recurring": true}},
+			{"id": "11", "content": "Пропылесосить", "parent_id": "10", "project_id": "2", "checked": true},
+			{"id": "12", "content": "Купить молоко", "project_id": "1",
+			 "due": {"date": "2026-10-20T09:00:00", "string": "Oct 20 9am", "is_recurring": false}},
+			{"id": 13, "content": "Подзадача без родителя", "parent_id": "99"}
+		]
+	}`
+	items, err := Parse(strings.NewReader(sync))
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+
+	assert.Equal(t, "Уборка", items[0].Content)
+	assert.Equal(t, "Дом", items[0].Project)
+	assert.Equal(t, []string{"выходные"}, items[0].Labels)
+	assert.Equal(t, "every saturday", items[0].Recurrence)
+	require.Len(t, items[0].Children, 1)
+	assert.True(t, items[0].Children[0].Completed)
+
+	assert.Empty(t, items[1].Project)
+	assert.Empty(t, items[1].Recurrence)
+	assert.Equal(t, "2026-10-20", items[1].Date.Format(isoFormat))
+	assert.Equal(t, "13", items[2].ID)
+
+	// Ответ REST API — массив задач.
+	items, err = Parse(strings.NewReader(`[{"id": "1", "content": "Задача", "is_completed": true}]`))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.True(t, items[0].Completed)
+
+	_, err = Parse(strings.NewReader(`{"cards": []}`))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader(`{"items": [{"id": "1", "due": {"date": "20.10.2026"}}]}`))
+	assert.Error(t, err)
+}
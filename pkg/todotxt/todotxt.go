@@ -0,0 +1,228 @@
+// Package todotxt переводит задачи в строки формата todo.txt и обратно
+// (https://github.com/todotxt/todo.txt).
+//
+// Название задачи становится описанием строки, поэтому +проекты, @контексты
+// и приоритет вида "(A) " живут в названии и переносятся без изменений.
+// Дата задачи записывается тегом due:, правило повторения — тегом rec:
+// в том виде, в каком его понимают приложения для todo.txt. Правила, у
+// которых нет аналога в rec:, и статусы кроме todo и done записываются
+// тегами repeat: и status:. Комментарий в todo.txt не переносится.
+//
+// Слова названия, которые при чтении приняли бы за отметку выполнения "x",
+// дату в начале строки или один из этих тегов, записываются с обратной
+// косой чертой в начале (\x, \2024-01-01, \due:2025-01-01); Parse её снимает.
+package todotxt
+
+import (
+	"errors"
+	"fmt"
+	"go1f/pkg/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateFormat = "20060102"
+	isoFormat  = "2006-01-02"
+)
+
+// Format возвращает задачу одной строкой todo.txt.
+func Format(task *db.Task) string {
+	var b strings.Builder
+	if task.Status == db.StatusDone {
+		b.WriteString("x ")
+	}
+	b.WriteString(strings.Join(escapeTitle(task.Title), " "))
+
+	if date, err := time.Parse(dateFormat, task.Date); err == nil {
+		b.WriteString(" due:" + date.Format(isoFormat))
+	}
+	if task.Repeat != "" {
+		if rec, ok := recOf(task.Repeat, task.Date); ok {
+			b.WriteString(" rec:" + rec)
+		} else {
+			b.WriteString(" repeat:" + strings.ReplaceAll(task.Repeat, " ", "_"))
+		}
+	}
+	if task.Status != "" && task.Status != db.StatusTodo && task.Status != db.StatusDone {
+		b.WriteString(" status:" + task.Status)
+	}
+	return b.String()
+}
+
+// Parse разбирает строку todo.txt. lost перечисляет то, что задача
+// не хранит: даты создания и выполнения, интервал rec:, который правило
+// повторения не выражает.
+func Parse(line string) (task *db.Task, lost []string, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil, errors.New("empty line")
+	}
+
+	task = &db.Task{Status: db.StatusTodo}
+	if fields[0] == "x" {
+		task.Status = db.StatusDone
+		fields = fields[1:]
+		if len(fields) > 0 && isDate(fields[0]) {
+			lost = append(lost, "completion date "+fields[0])
+			fields = fields[1:]
+		}
+	}
+	var title []string
+	if len(fields) > 0 && isPriority(fields[0]) {
+		title = append(title, fields[0])
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && isDate(fields[0]) {
+		lost = append(lost, "creation date "+fields[0])
+		fields = fields[1:]
+	}
+
+	var rec string
+	for _, f := range fields {
+		if strings.HasPrefix(f, `\`) {
+			title = append(title, f[1:])
+			continue
+		}
+		key, value, ok := tagOf(f)
+		if !ok {
+			title = append(title, f)
+			continue
+		}
+		switch key {
+		case "due":
+			date, err := time.Parse(isoFormat, value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid due date %q", value)
+			}
+			task.Date = date.Format(dateFormat)
+		case "rec":
+			rec = value
+		case "repeat":
+			task.Repeat = strings.ReplaceAll(value, "_", " ")
+		case "status":
+			if !db.ValidStatus(value) {
+				return nil, nil, fmt.Errorf("unknown task status %q", value)
+			}
+			task.Status = value
+		}
+	}
+	task.Title = strings.Join(title, " ")
+	if task.Title == "" {
+		return nil, nil, errors.New("task title is required")
+	}
+
+	if rec != "" {
+		repeat, dropped, err := repeatOf(rec, task.Date)
+		if err != nil {
+			return nil, nil, err
+		}
+		task.Repeat = repeat
+		lost = append(lost, dropped...)
+	}
+	return task, lost, nil
+}
+
+// escapeTitle разбивает название на слова и экранирует те, что Parse
+// прочитал бы не как часть названия.
+func escapeTitle(title string) []string {
+	words := strings.Fields(title)
+	for i, w := range words {
+		_, _, tag := tagOf(w)
+		leadingDate := isDate(w) && (i == 0 || i == 1 && isPriority(words[0]))
+		if tag || leadingDate || i == 0 && w == "x" || strings.HasPrefix(w, `\`) {
+			words[i] = `\` + w
+		}
+	}
+	return words
+}
+
+// tagOf разбирает слово как тег key:value. Третье значение равно false,
+// если это не тег, который понимает Parse; значения со "/" — ссылки.
+func tagOf(word string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(word, ":")
+	if !ok || value == "" || strings.Contains(value, "/") {
+		return "", "", false
+	}
+	switch key {
+	case "due", "rec", "repeat", "status":
+		return key, value, true
+	}
+	return "", "", false
+}
+
+// recOf переводит правило повторения в значение тега rec:. Второе значение
+// равно false, если у правила нет аналога.
+func recOf(repeat, date string) (string, bool) {
+	parts := strings.Fields(repeat)
+	switch {
+	case len(parts) == 2 && parts[0] == "d":
+		n, err := strconv.Atoi(parts[1])
+		if err == nil && n > 0 && n%7 == 0 {
+			return strconv.Itoa(n/7) + "w", true
+		}
+		if err == nil && n > 0 {
+			return parts[1] + "d", true
+		}
+	case len(parts) == 1 && parts[0] == "y":
+		return "1y", true
+	case len(parts) == 2 && parts[0] == "w" && parts[1] == "1,2,3,4,5":
+		return "1b", true
+	case len(parts) == 2 && parts[0] == "m":
+		// "m 15" для задачи на 15-е число — то же, что ежемесячно от даты задачи.
+		t, err := time.Parse(dateFormat, date)
+		if err == nil && parts[1] == strconv.Itoa(t.Day()) {
+			return "1m", true
+		}
+	}
+	return "", false
+}
+
+// repeatOf переводит значение тега rec: (например, 3d, +1w, 2m) в правило
+// повторения задачи с датой date. Знак + означает повтор от срока, а не от
+// даты выполнения; правило задачи всегда отсчитывает от срока.
+func repeatOf(rec, date string) (repeat string, lost []string, err error) {
+	value := strings.TrimPrefix(rec, "+")
+	if len(value) < 2 {
+		return "", nil, fmt.Errorf("invalid rec %q", rec)
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 1 {
+		return "", nil, fmt.Errorf("invalid rec %q", rec)
+	}
+
+	day := time.Now().UTC().Day()
+	if t, err := time.Parse(dateFormat, date); err == nil {
+		day = t.Day()
+	}
+
+	unit := value[len(value)-1]
+	switch unit {
+	case 'd':
+		repeat = fmt.Sprintf("d %d", n)
+	case 'w':
+		repeat = fmt.Sprintf("d %d", 7*n)
+	case 'b':
+		repeat = "w 1,2,3,4,5"
+	case 'm':
+		repeat = fmt.Sprintf("m %d", day)
+	case 'y':
+		repeat = "y"
+	default:
+		return "", nil, fmt.Errorf("invalid rec %q", rec)
+	}
+	if n > 1 && (unit == 'b' || unit == 'm' || unit == 'y') {
+		lost = append(lost, "interval of rec:"+rec)
+	}
+	return repeat, lost, nil
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse(isoFormat, s)
+	return err == nil
+}
+
+func isPriority(s string) bool {
+	return len(s) == 3 && s[0] == '(' && s[1] >= 'A' && s[1] <= 'Z' && s[2] == ')'
+}
@@ -0,0 +1,90 @@
+package todotxt
+
+import (
+	"testing"
+
+	"go1f/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	tbl := []struct {
+		task db.Task
+		line string
+	}{
+		{db.Task{Title: "Купить хлеб", Date: "20261020", Status: db.StatusTodo},
+			"Купить хлеб due:2026-10-20"},
+		{db.Task{Title: "(A) Позвонить +семья @телефон", Date: "20261020", Repeat: "d 7", Status: db.StatusTodo},
+			"(A) Позвонить +семья @телефон due:2026-10-20 rec:1w"},
+		{db.Task{Title: "Зарядка", Date: "20261019", Repeat: "w 1,2,3,4,5", Status: db.StatusInProgress},
+			"Зарядка due:2026-10-19 rec:1b status:in_progress"},
+		{db.Task{Title: "Полив", Date: "20261020", Repeat: "d 3", Status: db.StatusTodo},
+			"Полив due:2026-10-20 rec:3d"},
+		{db.Task{Title: "Квартплата", Date: "20261025", Repeat: "m 25"},
+			"Квартплата due:2026-10-25 rec:1m"},
+		{db.Task{Title: "Отчёт", Date: "20261031", Repeat: "m 1,-1 3,6,9,12"},
+			"Отчёт due:2026-10-31 repeat:m_1,-1_3,6,9,12"},
+		{db.Task{Title: "Сдать книги", Date: "20261001", Status: db.StatusDone},
+			"x Сдать книги due:2026-10-01"},
+		// Слова, которые прочитались бы как отметка, дата или тег, экранируются.
+		{db.Task{Title: "x marks the spot", Date: "20261020", Status: db.StatusTodo},
+			`\x marks the spot due:2026-10-20`},
+		{db.Task{Title: "2024-01-01 review notes", Date: "20261020", Status: db.StatusDone},
+			`x \2024-01-01 review notes due:2026-10-20`},
+		{db.Task{Title: "(B) 2024-01-01 review notes", Date: "20261020", Status: db.StatusTodo},
+			`(B) \2024-01-01 review notes due:2026-10-20`},
+		{db.Task{Title: "read about due:2025-01-01 field", Date: "20261020", Status: db.StatusTodo},
+			`read about \due:2025-01-01 field due:2026-10-20`},
+		{db.Task{Title: `rec:1w status:done \x pri:A`, Date: "20261020", Status: db.StatusTodo},
+			`\rec:1w \status:done \\x pri:A due:2026-10-20`},
+	}
+	for _, v := range tbl {
+		line := Format(&v.task)
+		assert.Equal(t, v.line, line)
+
+		// Строка читается обратно в ту же задачу.
+		task, lost, err := Parse(line)
+		require.NoError(t, err, line)
+		assert.Empty(t, lost, line)
+		want := v.task
+		if want.Status == "" {
+			want.Status = db.StatusTodo
+		}
+		assert.Equal(t, want, *task, line)
+	}
+}
+
+func TestParse(t *testing.T) {
+	task, lost, err := Parse("x 2026-10-02 2026-09-01 Полить цветы @дом rec:+2w due:2026-10-03 http://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, db.StatusDone, task.Status)
+	assert.Equal(t, "Полить цветы @дом http://example.com", task.Title)
+	assert.Equal(t, "20261003", task.Date)
+	assert.Equal(t, "d 14", task.Repeat)
+	assert.Equal(t, []string{"completion date 2026-10-02", "creation date 2026-09-01"}, lost)
+
+	task, lost, err = Parse("(B) Налоги rec:3m due:2026-04-30")
+	require.NoError(t, err)
+	assert.Equal(t, "(B) Налоги", task.Title)
+	assert.Equal(t, "m 30", task.Repeat)
+	assert.Equal(t, []string{"interval of rec:3m"}, lost)
+
+	task, _, err = Parse("Без срока pri:A")
+	require.NoError(t, err)
+	assert.Empty(t, task.Date)
+	assert.Equal(t, "Без срока pri:A", task.Title)
+
+	for _, line := range []string{
+		"",
+		"due:2026-10-03",
+		"Задача due:03.10.2026",
+		"Задача rec:1q",
+		"Задача rec:0d",
+		"Задача status:unknown",
+	} {
+		_, _, err := Parse(line)
+		assert.Error(t, err, line)
+	}
+}
@@ -0,0 +1,135 @@
+// Package trello читает выгрузку доски Trello в JSON (Menu → Print, export
+// and share → Export as JSON).
+package trello
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Card — карточка доски вместе с её колонкой, метками и чек-листами.
+type Card struct {
+	ID   string
+	Name string
+	Desc string
+	List string
+	// Archived — карточка или её колонка в архиве.
+	Archived bool
+	// Date — срок карточки; нулевой, если срок не задан.
+	Date       time.Time
+	Done       bool
+	Labels     []string
+	Checklists []Checklist
+}
+
+type Checklist struct {
+	Name  string
+	Items []CheckItem
+}
+
+type CheckItem struct {
+	Name string
+	Done bool
+}
+
+type board struct {
+	Cards *[]struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		Desc        string   `json:"desc"`
+		Closed      bool     `json:"closed"`
+		Due         *string  `json:"due"`
+		DueComplete bool     `json:"dueComplete"`
+		IDList      string   `json:"idList"`
+		IDLabels    []string `json:"idLabels"`
+		Pos         float64  `json:"pos"`
+	} `json:"cards"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Labels []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	} `json:"labels"`
+	Checklists []struct {
+		ID         string  `json:"id"`
+		IDCard     string  `json:"idCard"`
+		Name       string  `json:"name"`
+		Pos        float64 `json:"pos"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+// Parse читает карточки доски. Метка без названия называется по цвету.
+func Parse(r io.Reader) ([]*Card, error) {
+	var b board
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("error reading Trello JSON: %v", err)
+	}
+	if b.Cards == nil {
+		return nil, errors.New("not a Trello board export: no cards")
+	}
+
+	lists := make(map[string]string)
+	closedLists := make(map[string]bool)
+	for _, l := range b.Lists {
+		lists[l.ID] = l.Name
+		closedLists[l.ID] = l.Closed
+	}
+	labels := make(map[string]string)
+	for _, l := range b.Labels {
+		labels[l.ID] = l.Name
+		if l.Name == "" {
+			labels[l.ID] = l.Color
+		}
+	}
+
+	sort.SliceStable(b.Checklists, func(i, j int) bool { return b.Checklists[i].Pos < b.Checklists[j].Pos })
+	checklists := make(map[string][]Checklist)
+	for _, cl := range b.Checklists {
+		sort.SliceStable(cl.CheckItems, func(i, j int) bool { return cl.CheckItems[i].Pos < cl.CheckItems[j].Pos })
+		list := Checklist{Name: cl.Name}
+		for _, item := range cl.CheckItems {
+			list.Items = append(list.Items, CheckItem{Name: item.Name, Done: item.State == "complete"})
+		}
+		checklists[cl.IDCard] = append(checklists[cl.IDCard], list)
+	}
+
+	cards := make([]*Card, 0, len(*b.Cards))
+	for _, c := range *b.Cards {
+		card := &Card{
+			ID:         c.ID,
+			Name:       c.Name,
+			Desc:       c.Desc,
+			List:       lists[c.IDList],
+			Archived:   c.Closed || closedLists[c.IDList],
+			Done:       c.DueComplete,
+			Checklists: checklists[c.ID],
+		}
+		if c.Due != nil && *c.Due != "" {
+			due, err := time.Parse(time.RFC3339, *c.Due)
+			if err != nil {
+				return nil, fmt.Errorf("card %s: invalid due date %q", c.ID, *c.Due)
+			}
+			card.Date = due
+		}
+		for _, id := range c.IDLabels {
+			if name := labels[id]; name != "" {
+				card.Labels = append(card.Labels, name)
+			}
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
@@ -0,0 +1,58 @@
+package trello
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	export := `{
+		"name": "Ремонт",
+		"lists": [
+			{"id": "l1", "name": "Сделать", "closed": false},
+			{"id": "l2", "name": "Старое", "closed": true}
+		],
+		"labels": [
+			{"id": "b1", "name": "срочно", "color": "red"},
+			{"id": "b2", "name": "", "color": "green"}
+		],
+		"cards": [
+			{"id": "c1", "name": "Покрасить стены", "desc": "Белая краска", "idList": "l1",
+			 "idLabels": ["b1", "b2"], "due": "2026-10-20T09:00:00.000Z", "dueComplete": false},
+			{"id": "c2", "name": "Выбрать обои", "idList": "l2", "due": null},
+			{"id": "c3", "name": "Купить кисти", "idList": "l1", "closed": true, "dueComplete": true}
+		],
+		"checklists": [
+			{"id": "k2", "idCard": "c1", "name": "Материалы", "pos": 2,
+			 "checkItems": [{"name": "Валик", "state": "complete", "pos": 2}, {"name": "Краска", "state": "incomplete", "pos": 1}]},
+			{"id": "k1", "idCard": "c1", "name": "Подготовка", "pos": 1,
+			 "checkItems": [{"name": "Снять розетки", "state": "incomplete", "pos": 1}]}
+		]
+	}`
+	cards, err := Parse(strings.NewReader(export))
+	require.NoError(t, err)
+	require.Len(t, cards, 3)
+
+	c := cards[0]
+	assert.Equal(t, "Покрасить стены", c.Name)
+	assert.Equal(t, "Сделать", c.List)
+	assert.Equal(t, "2026-10-20", c.Date.Format("2006-01-02"))
+	assert.Equal(t, []string{"срочно", "green"}, c.Labels)
+	require.Len(t, c.Checklists, 2)
+	assert.Equal(t, "Подготовка", c.Checklists[0].Name)
+	assert.Equal(t, []CheckItem{{Name: "Краска"}, {Name: "Валик", Done: true}}, c.Checklists[1].Items)
+	assert.False(t, c.Archived)
+
+	assert.True(t, cards[1].Archived, "карточка в архивной колонке")
+	assert.True(t, cards[1].Date.IsZero())
+	assert.True(t, cards[2].Archived)
+	assert.True(t, cards[2].Done)
+
+	_, err = Parse(strings.NewReader(`{"items": []}`))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader(`{"cards": [{"id": "c1", "due": "завтра"}]}`))
+	assert.Error(t, err)
+}
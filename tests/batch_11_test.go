@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type batchResp struct {
+	Committed bool `json:"committed"`
+	Results   []struct {
+		ID    string `json:"id"`
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	} `json:"results"`
+}
+
+func batch(t *testing.T, atomic bool, ops []map[string]any) batchResp {
+	body, err := requestJSON("api/tasks/batch", map[string]any{
+		"atomic": atomic,
+		"ops":    ops,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	var resp batchResp
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestBatch(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	first := addTask(t, task{date: today, title: "Разобрать почту"})
+	second := addTask(t, task{date: today, title: "Сверить счета", repeat: "d 7"})
+	third := addTask(t, task{date: today, title: "Старая заметка"})
+
+	date := now.AddDate(0, 0, 5).Format(`20060102`)
+	resp := batch(t, true, []map[string]any{
+		{"op": "done", "id": first, "version": "1"},
+		{"op": "reschedule", "id": second, "date": date, "version": "1"},
+		{"op": "delete", "id": "7645346343"},
+	})
+	assert.False(t, resp.Committed)
+	assert.Len(t, resp.Results, 3)
+	assert.NotEmpty(t, resp.Results[2].Error)
+
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, first))
+	assert.Equal(t, "todo", stored.Status)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, second))
+	assert.Equal(t, today, stored.Date)
+
+	resp = batch(t, false, []map[string]any{
+		{"op": "done", "id": first, "version": "1"},
+		{"op": "reschedule", "id": second, "date": date, "version": "1"},
+		{"op": "update", "id": third, "title": "", "version": "1"},
+		{"op": "update", "id": third, "comment": "Уточнить у коллег", "version": "1"},
+		{"op": "ooops", "id": third},
+	})
+	assert.True(t, resp.Committed)
+	assert.True(t, resp.Results[0].OK)
+	assert.True(t, resp.Results[1].OK)
+	assert.False(t, resp.Results[2].OK)
+	assert.True(t, resp.Results[3].OK)
+	assert.False(t, resp.Results[4].OK)
+
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, first))
+	assert.Equal(t, "done", stored.Status)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, second))
+	assert.Equal(t, date, stored.Date)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, third))
+	assert.Equal(t, "Старая заметка", stored.Title)
+	assert.Equal(t, "Уточнить у коллег", stored.Comment)
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentDone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Сделать зарядку",
+		repeat: "d 1",
+	})
+
+	const requests = 20
+	var wg sync.WaitGroup
+	errs := make(chan any, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// If-Match: * явно отключает проверку версии: все запросы
+			// завершают одну и ту же задачу.
+			_, ret := requestWithHeaders(t, "api/task/done?id="+id, nil, http.MethodPost,
+				map[string]string{"If-Match": "*"})
+			if e, ok := ret["error"]; ok {
+				errs <- e
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Errorf("Неожиданная ошибка: %v", e)
+	}
+
+	// Каждый запрос сдвигает задачу ровно на один день.
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, now.AddDate(0, 0, requests).Format(`20060102`), stored.Date)
+	assert.Equal(t, int64(requests+1), stored.Version)
+
+	var done int
+	assert.NoError(t, db.Get(&done, `SELECT count(*) FROM revision WHERE task_id=? AND action='done'`, id))
+	assert.Equal(t, requests, done)
+}
@@ -16,6 +16,9 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+	Status  string `db:"status"`
+	Version int64  `db:"version"`
+	Anchor  string `db:"anchor"`
 }
 
 func count(db *sqlx.DB) (int, error) {
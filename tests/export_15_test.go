@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	id := addTask(t, task{date: today, title: "Выгрузить отчёт", comment: "в CSV, с запятыми", repeat: "d 5"})
+
+	body, err := requestJSON("api/export?format=json", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var exported struct {
+		Tasks []map[string]string `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &exported))
+	var found map[string]string
+	for _, task := range exported.Tasks {
+		if task["id"] == id {
+			found = task
+		}
+	}
+	assert.Equal(t, "в CSV, с запятыми", found["comment"])
+	assert.Equal(t, "d 5", found["repeat"])
+
+	body, err = requestJSON("api/export?format=csv", nil, http.MethodGet)
+	assert.NoError(t, err)
+	records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "date", "title", "comment", "repeat", "status"}, records[0])
+	assert.Len(t, records, len(exported.Tasks)+1)
+
+	// Строка с ошибкой отменяет весь импорт.
+	ret, err := postJSON("api/import?mode=merge", map[string]any{"tasks": []map[string]any{
+		{"id": id, "date": today, "title": "Выгрузить отчёт за месяц", "repeat": "d 5"},
+		{"date": today, "title": "Неверный повтор", "repeat": "d 0"},
+	}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["errors"])
+
+	var title string
+	assert.NoError(t, db.Get(&title, `SELECT title FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Выгрузить отчёт", title)
+
+	ret, err = postJSON("api/import?mode=merge", map[string]any{"tasks": []map[string]any{
+		{"id": id, "date": today, "title": "Выгрузить отчёт за месяц", "repeat": "d 5"},
+		{"date": today, "title": "Импортированная задача"},
+	}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), ret["imported"])
+
+	assert.NoError(t, db.Get(&title, `SELECT title FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Выгрузить отчёт за месяц", title)
+	var count int
+	assert.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM scheduler WHERE title=?`, "Импортированная задача"))
+	assert.Equal(t, 1, count)
+}
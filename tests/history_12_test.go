@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type revision struct {
+	ID     string            `json:"id"`
+	Action string            `json:"action"`
+	Actor  string            `json:"actor"`
+	Before map[string]string `json:"before"`
+	After  map[string]string `json:"after"`
+}
+
+func history(t *testing.T, id string) []revision {
+	body, err := requestJSON("api/task/history?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m struct {
+		Revisions []revision `json:"revisions"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m.Revisions
+}
+
+func TestHistory(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	id := addTask(t, task{date: today, title: "Купить билеты", repeat: "d 7"})
+
+	_, ret := requestWithHeaders(t, "api/task", map[string]any{
+		"id":     id,
+		"date":   today,
+		"title":  "Купить билеты в театр",
+		"repeat": "d 14",
+	}, http.MethodPut, ifMatch(t, id))
+	assert.Empty(t, ret)
+
+	_, ret = requestWithHeaders(t, "api/task/done?id="+id, nil, http.MethodPost, ifMatch(t, id))
+	assert.Empty(t, ret)
+
+	_, ret = requestWithHeaders(t, "api/task?id="+id, nil, http.MethodDelete, ifMatch(t, id))
+	assert.Empty(t, ret)
+
+	revs := history(t, id)
+	assert.Len(t, revs, 4)
+	actions := make([]string, 0, len(revs))
+	for _, rev := range revs {
+		actions = append(actions, rev.Action)
+		assert.NotEmpty(t, rev.Actor)
+	}
+	assert.Equal(t, []string{"insert", "update", "done", "delete"}, actions)
+	assert.Nil(t, revs[0].Before)
+	assert.Equal(t, "d 7", revs[1].Before["repeat"])
+	assert.Equal(t, "d 14", revs[1].After["repeat"])
+	assert.Equal(t, today, revs[2].Before["date"])
+	assert.Equal(t, now.AddDate(0, 0, 14).Format(`20060102`), revs[2].After["date"])
+	assert.Nil(t, revs[3].After)
+
+	// Возвращаем удалённую задачу к исходному состоянию.
+	ret, err := postJSON("api/task/revert", map[string]any{
+		"id":       id,
+		"revision": revs[0].ID,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "Купить билеты", ret["title"])
+
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "d 7", stored.Repeat)
+	assert.Equal(t, today, stored.Date)
+	assert.Equal(t, "revert", history(t, id)[4].Action)
+
+	ret, err = postJSON("api/task/revert", map[string]any{
+		"id":       "7645346343",
+		"revision": revs[0].ID,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnooze(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Продлить подписку",
+		repeat: "d 30",
+	})
+
+	snooze := func(values map[string]any) map[string]any {
+		values["id"] = id
+		ret, err := postJSON("api/task/snooze", values, http.MethodPost)
+		assert.NoError(t, err)
+		return ret
+	}
+
+	ret := snooze(map[string]any{"by": "+1d"})
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), ret["date"])
+	ret = snooze(map[string]any{"by": "+1w"})
+	assert.Equal(t, now.AddDate(0, 0, 8).Format(`20060102`), ret["date"])
+
+	date := now.AddDate(0, 2, 0).Format(`20060102`)
+	ret = snooze(map[string]any{"date": date})
+	assert.Equal(t, date, ret["date"])
+
+	var stored Task
+	err := db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, date, stored.Date)
+	assert.Equal(t, "d 30", stored.Repeat)
+
+	ret = snooze(map[string]any{"by": "next monday"})
+	next, err := time.Parse(`20060102`, ret["date"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Monday, next.Weekday())
+
+	// Перенос не сдвигает расписание: выполненная задача возвращается
+	// к повторам от исходной даты.
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Format(`20060102`), stored.Anchor)
+	_, ret = requestWithHeaders(t, "api/task/done?id="+id, nil, http.MethodPost, ifMatch(t, id))
+	assert.Empty(t, ret)
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 30).Format(`20060102`), stored.Date)
+	assert.Empty(t, stored.Anchor)
+
+	for _, v := range []map[string]any{
+		{},
+		{"by": "+1d", "date": date},
+		{"by": "soon"},
+		{"date": "20000101"},
+	} {
+		ret = snooze(v)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для %v", v)
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setStatus(t *testing.T, id, status string) map[string]any {
+	_, ret := requestWithHeaders(t, "api/task/status", map[string]any{
+		"id":     id,
+		"status": status,
+	}, http.MethodPost, ifMatch(t, id))
+	return ret
+}
+
+func TestStatus(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Подготовить отчёт",
+	})
+
+	var stored Task
+	err := db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "todo", stored.Status)
+
+	assert.Empty(t, setStatus(t, id, "in_progress"))
+	assert.Empty(t, setStatus(t, id, "waiting"))
+	assert.NotEmpty(t, setStatus(t, id, "ooops"))
+	assert.Empty(t, setStatus(t, id, "done"))
+	// Из "done" можно только вернуть задачу в работу.
+	assert.NotEmpty(t, setStatus(t, id, "waiting"))
+
+	found := func(status string) bool {
+		body, err := requestJSON("api/tasks?status="+status, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string][]map[string]string
+		assert.NoError(t, json.Unmarshal(body, &m))
+		for _, v := range m["tasks"] {
+			if v["id"] == id {
+				return true
+			}
+		}
+		return false
+	}
+	assert.False(t, found(""))
+	assert.True(t, found("done"))
+	assert.True(t, found("all"))
+
+	assert.Empty(t, setStatus(t, id, "todo"))
+	assert.True(t, found(""))
+	assert.False(t, found("done"))
+
+	id = addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Полить цветы",
+		repeat: "d 2",
+	})
+	assert.Empty(t, setStatus(t, id, "in_progress"))
+	assert.Empty(t, setStatus(t, id, "done"))
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "todo", stored.Status)
+	assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), stored.Date)
+}
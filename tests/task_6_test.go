@@ -74,7 +74,7 @@ func TestEditTask(t *testing.T) {
 		{"7645346343", task{"20240129", "Тест", "", ""}},
 		{id, task{"20240129", "", "", ""}},
 		{id, task{"20240192", "Qwerty", "", ""}},
-		{id, task{"28.01.2024", "Заголовок", "", ""}},
+		{id, task{"28.13.2024", "Заголовок", "", ""}},
 		{id, task{"20240212", "Заголовок", "", "ooops"}},
 	}
 	for _, v := range tbl {
@@ -96,14 +96,13 @@ func TestEditTask(t *testing.T) {
 	}
 
 	updateTask := func(newVals map[string]any) {
-		mupd, err := postJSON("api/task", newVals, http.MethodPut)
-		assert.NoError(t, err)
+		_, mupd := requestWithHeaders(t, "api/task", newVals, http.MethodPut, ifMatch(t, id))
 
 		e, ok := mupd["error"]
 		assert.False(t, ok && fmt.Sprint(e) != "")
 
 		var task Task
-		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
 		assert.NoError(t, err)
 
 		assert.Equal(t, id, strconv.FormatInt(task.ID, 10))
@@ -29,10 +29,16 @@ func TestDone(t *testing.T) {
 		title: "Свести баланс",
 	})
 
-	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
-	assert.NoError(t, err)
+	_, ret := requestWithHeaders(t, "api/task/done?id="+id, nil, http.MethodPost, ifMatch(t, id))
 	assert.Empty(t, ret)
-	notFoundTask(t, id)
+
+	var done Task
+	err := db.Get(&done, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", done.Status)
+
+	_, ret = requestWithHeaders(t, "api/task/done?id="+id, nil, http.MethodPost, ifMatch(t, id))
+	assert.NotEmpty(t, ret)
 
 	id = addTask(t, task{
 		title:  "Проверить работу /api/task/done",
@@ -40,8 +46,7 @@ func TestDone(t *testing.T) {
 	})
 
 	for i := 0; i < 3; i++ {
-		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
-		assert.NoError(t, err)
+		_, ret := requestWithHeaders(t, "api/task/done?id="+id, nil, http.MethodPost, ifMatch(t, id))
 		assert.Empty(t, ret)
 
 		var task Task
@@ -60,13 +65,12 @@ func TestDelTask(t *testing.T) {
 		title:  "Временная задача",
 		repeat: "d 3",
 	})
-	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
-	assert.NoError(t, err)
+	_, ret := requestWithHeaders(t, "api/task?id="+id, nil, http.MethodDelete, ifMatch(t, id))
 	assert.Empty(t, ret)
 
 	notFoundTask(t, id)
 
-	ret, err = postJSON("api/task", nil, http.MethodDelete)
+	ret, err := postJSON("api/task", nil, http.MethodDelete)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, ret)
 	ret, err = postJSON("api/task?id=wjhgese", nil, http.MethodDelete)
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ret, err := postJSON("api/template", map[string]any{
+		"name": "Новый сотрудник",
+		"tasks": []map[string]any{
+			{"offset": 0, "title": "Выдать ноутбук"},
+			{"offset": 1, "title": "Настроить доступы", "comment": "VPN, почта"},
+			{"offset": 14, "title": "Встреча один на один", "repeat": "d 14"},
+		},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"])
+	tplID := fmt.Sprint(ret["id"])
+
+	for _, v := range []map[string]any{
+		{"name": "", "tasks": []map[string]any{{"title": "Задача"}}},
+		{"name": "Пустой"},
+		{"name": "Без заголовка", "tasks": []map[string]any{{"title": ""}}},
+		{"name": "Плохой повтор", "tasks": []map[string]any{{"title": "Задача", "repeat": "ooops"}}},
+	} {
+		ret, err = postJSON("api/template", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для %v", v)
+	}
+
+	start := time.Now().AddDate(0, 0, 3)
+	body, err := requestJSON("api/template/apply", map[string]any{
+		"id":   tplID,
+		"date": start.Format(`20060102`),
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	var applied struct {
+		IDs []string `json:"ids"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &applied))
+	assert.Len(t, applied.IDs, 3)
+
+	offsets := []int{0, 1, 14}
+	for i, id := range applied.IDs {
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, start.AddDate(0, 0, offsets[i]).Format(`20060102`), task.Date)
+	}
+
+	ret, err = postJSON("api/template?id="+tplID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	ret, err = postJSON("api/template/apply", map[string]any{"id": tplID}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestWithHeaders(t *testing.T, apipath string, values map[string]any, method string,
+	headers map[string]string) (*http.Response, map[string]any) {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return resp, m
+}
+
+// ifMatch возвращает заголовок If-Match с текущей версией задачи из ETag.
+func ifMatch(t *testing.T, id string) map[string]string {
+	resp, _ := requestWithHeaders(t, "api/task?id="+id, nil, http.MethodGet, nil)
+	return map[string]string{"If-Match": resp.Header.Get("ETag")}
+}
+
+func TestVersion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	id := addTask(t, task{date: today, title: "Обновить резюме", repeat: "d 10"})
+
+	resp, m := requestWithHeaders(t, "api/task?id="+id, nil, http.MethodGet, nil)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	assert.Equal(t, "1", m["version"])
+
+	update := map[string]any{
+		"id":     id,
+		"date":   today,
+		"title":  "Обновить резюме и портфолио",
+		"repeat": "d 10",
+	}
+	resp, m = requestWithHeaders(t, "api/task", update, http.MethodPut, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	assert.Empty(t, m)
+
+	// Вторая вкладка всё ещё держит версию 1.
+	update["title"] = "Старое название"
+	resp, m = requestWithHeaders(t, "api/task", update, http.MethodPut, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	assert.NotEmpty(t, m["error"])
+	current, ok := m["task"].(map[string]any)
+	assert.True(t, ok)
+	assert.Equal(t, "Обновить резюме и портфолио", current["title"])
+	assert.Equal(t, "2", current["version"])
+
+	update["version"] = "1"
+	resp, _ = requestWithHeaders(t, "api/task", update, http.MethodPut, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _ = requestWithHeaders(t, "api/task/done?id="+id+"&version=1", nil, http.MethodPost, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, m = requestWithHeaders(t, "api/task/done?id="+id, nil, http.MethodPost, map[string]string{"If-Match": `"2"`})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, m)
+
+	resp, _ = requestWithHeaders(t, "api/task?id="+id, nil, http.MethodDelete, map[string]string{"If-Match": `"2"`})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, m = requestWithHeaders(t, "api/task?id="+id, nil, http.MethodDelete, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, resp.StatusCode)
+	assert.NotEmpty(t, m["error"])
+	resp, _ = requestWithHeaders(t, "api/task?id="+id+"&version=3", nil, http.MethodDelete, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	notFoundTask(t, id)
+}